@@ -9,26 +9,41 @@ import (
 	"github.com/zachvanuum/FoodHelperBot/util"
 )
 
-func ReceiveMessageHandler(svc service.TelegramService) func(w http.ResponseWriter, r *http.Request) {
+// ReceiveMessageHandler queues updates Telegram sends to the webhook and
+// answers straight away, so a slow reply doesn't make Telegram send the
+// update again. Requests without secretToken in the SecretTokenHeader header
+// didn't come from Telegram and are rejected.
+func ReceiveMessageHandler(queue *service.UpdateQueue, secretToken string) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !hasSecretToken(r, secretToken) {
+			log.Printf("[ReceiveMessageHandler] Rejected request without secret token from %s", r.RemoteAddr)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
 		var message model.ReceivedMessage
 
 		if err := util.UnmarshalBody(r.Body, &message); err != nil {
-			log.Printf("[ReceiveMessageHandler] failed marshall sendMessage response to struct: %s", err.Error())
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			log.Printf("[ReceiveMessageHandler] failed marshall update to struct: %s", err.Error())
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
 		}
 
 		log.Printf(
-			"[ReceiveMessageHandler] Got message - chat ID: %d, message ID: %d, user ID: %d, text: \"%s\"",
+			"[ReceiveMessageHandler] Got message - update ID: %d, chat ID: %d, message ID: %d, user ID: %d, text: \"%s\"",
+			message.UpdateID,
 			message.Message.Chat.ID,
 			message.Message.MessageID,
 			message.Message.From.ID,
 			message.Message.Text,
 		)
 
-		if err := svc.RespondToMessage(message); err != nil {
-			log.Printf("[ReceiveMessageHandler] Error responding to message: %s", err.Error())
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		// Telegram delivers the update again later when it gets an error, so
+		// turning updates away when busy is safe.
+		if err := queue.Enqueue(message); err != nil {
+			log.Printf("[ReceiveMessageHandler] Failed to queue update %d: %s", message.UpdateID, err.Error())
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
 		}
 
 		w.WriteHeader(http.StatusOK)
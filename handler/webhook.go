@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// SecretTokenHeader carries the secret token given to Telegram when
+// registering the webhook.
+const SecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// TelegramNetworks are the ranges Telegram sends webhook requests from, see
+// https://core.telegram.org/bots/webhooks.
+var TelegramNetworks = []string{"149.154.160.0/20", "91.108.4.0/22"}
+
+func hasSecretToken(r *http.Request, secretToken string) bool {
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(SecretTokenHeader)), []byte(secretToken)) == 1
+}
+
+// AllowNetworks only lets requests through to next when they come from one of
+// networks. With trustForwardedFor, the client address is taken from the
+// last X-Forwarded-For entry, which is what a reverse proxy in front of the
+// bot adds.
+func AllowNetworks(networks []string, trustForwardedFor bool, next http.HandlerFunc) (http.HandlerFunc, error) {
+	allowed := []*net.IPNet{}
+	for _, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, fmt.Errorf("bad network %s in allowlist: %s", network, err.Error())
+		}
+
+		allowed = append(allowed, ipNet)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := sourceIP(r, trustForwardedFor)
+		for _, ipNet := range allowed {
+			if ip != nil && ipNet.Contains(ip) {
+				next(w, r)
+				return
+			}
+		}
+
+		log.Printf("[AllowNetworks] Rejected request from %s", r.RemoteAddr)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	}, nil
+}
+
+func sourceIP(r *http.Request, trustForwardedFor bool) net.IP {
+	if forwardedFor := r.Header.Get("X-Forwarded-For"); trustForwardedFor && forwardedFor != "" {
+		addresses := strings.Split(forwardedFor, ",")
+		return net.ParseIP(strings.TrimSpace(addresses[len(addresses)-1]))
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -16,6 +22,7 @@ import (
 type Services struct {
 	TelegramService service.TelegramService
 	YelpService     service.YelpService
+	PlaceProvider   service.PlaceProvider
 }
 
 type Flags struct {
@@ -23,8 +30,16 @@ type Flags struct {
 	Port   string
 	Cert   string
 	Key    string
+	Mode   string
 }
 
+const (
+	pollMode    = "poll"
+	webhookMode = "webhook"
+
+	defaultDrainTimeout = 30 * time.Second
+)
+
 func main() {
 	flags := getFlags()
 
@@ -33,31 +48,155 @@ func main() {
 		log.Fatalf("[main] Fatal error config file: %s \n", err.Error())
 	}
 
-	services := createServices(viper.GetString("telegram_key"), viper.GetString("yelp_key"))
-	routes := createRoutes(services)
-	server := createServer(flags.Port, routes)
+	sessions, err := service.NewSessionStore(viper.GetString("session.store"), viper.GetString("session.path"))
+	if err != nil {
+		log.Fatalf("[main] Failed to create session store: %s", err.Error())
+	}
+
+	services, err := createServices(viper.GetString("telegram_key"), viper.GetString("yelp_key"), sessions)
+	if err != nil {
+		log.Fatalf("[main] Failed to create services: %s", err.Error())
+	}
+
+	switch flags.Mode {
+	case pollMode:
+		runPolling(services)
+	case webhookMode:
+		runWebhook(services, flags)
+	default:
+		log.Fatalf("[main] Unknown mode %s, expected %s or %s", flags.Mode, webhookMode, pollMode)
+	}
+
+	if err := sessions.Close(); err != nil {
+		log.Printf("[main] Failed to close session store: %s", err.Error())
+	}
+}
+
+func runPolling(services *Services) {
+	if err := services.TelegramService.DeleteWebhook(); err != nil {
+		log.Fatalf("[main] Failed to remove webhook before polling: %s", err.Error())
+	}
+
+	log.Printf("[main] Polling for updates\n")
 
-	log.Printf("[main] Starting server on %s\n", flags.Port)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	if flags.Cert != "" || flags.Key != "" {
+	polling := make(chan struct{})
+	go func() {
+		services.TelegramService.PollUpdates(ctx)
+		close(polling)
+	}()
+
+	waitForShutdown()
+
+	// Stop polling, then give the update being handled time to finish
+	cancel()
+
+	select {
+	case <-polling:
+	case <-time.After(drainTimeout()):
+		log.Printf("[main] Gave up waiting for the update being handled")
+	}
+}
+
+func runWebhook(services *Services, flags Flags) {
+	secretToken, err := webhookSecretToken()
+	if err != nil {
+		log.Fatalf("[main] Failed to create webhook secret token: %s", err.Error())
+	}
+
+	webhookURL := viper.GetString("self_webhook_url")
+	if err := services.TelegramService.RegisterWebhook(webhookURL, secretToken); err != nil {
+		log.Fatalf("[main] Failed to register webhook for bot using url %s: %s", webhookURL, err.Error())
+	}
+
+	if webhookInfo, err := services.TelegramService.GetWebhookInfo(); err != nil {
+		log.Printf("[main] Failed to get webhook info: %s", err.Error())
+	} else {
+		log.Printf("[main] Webhook registered with %d pending updates\n", webhookInfo.PendingUpdateCount)
+	}
+
+	if (flags.Cert != "") != (flags.Key != "") {
 		if flags.Cert == "" {
 			log.Fatal("[main] Missing certificate, exiting")
-		} else if flags.Key == "" {
+		} else {
 			log.Fatal("[main] Missing key, exiting")
 		}
+	}
 
-		log.Fatal(server.ListenAndServeTLS(flags.Cert, flags.Key))
-	} else {
-		log.Fatal(server.ListenAndServe())
+	seen, err := service.NewSeenUpdates(viper.GetInt("updates.seen.size"), viper.GetString("updates.seen.path"), viper.GetDuration("updates.seen.save_interval"))
+	if err != nil {
+		log.Fatalf("[main] Failed to load seen updates: %s", err.Error())
+	}
+
+	queue := service.NewUpdateQueue(viper.GetInt("updates.workers"), viper.GetInt("updates.queue_size"), seen, services.TelegramService.RespondToMessage)
+
+	routes, err := createRoutes(services, queue, secretToken)
+	if err != nil {
+		log.Fatalf("[main] Failed to create routes: %s", err.Error())
+	}
+
+	server := createServer(flags.Port, routes)
+
+	go func() {
+		log.Printf("[main] Starting server on %s\n", flags.Port)
+
+		var err error
+		if flags.Cert != "" {
+			err = server.ListenAndServeTLS(flags.Cert, flags.Key)
+		} else {
+			err = server.ListenAndServe()
+		}
+
+		if err != http.ErrServerClosed {
+			log.Fatalf("[main] Server failed: %s", err.Error())
+		}
+	}()
+
+	waitForShutdown()
+
+	// Stop taking new updates first, then finish the ones already accepted
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout())
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("[main] Failed to shut down server: %s", err.Error())
+	}
+
+	if err := queue.Close(ctx); err != nil {
+		log.Printf("[main] Gave up waiting for queued updates: %s", err.Error())
+	}
+
+	if err := seen.Close(); err != nil {
+		log.Printf("[main] Failed to save seen updates: %s", err.Error())
 	}
 }
 
+// drainTimeout is how long shutting down waits for updates being handled.
+func drainTimeout() time.Duration {
+	if timeout := viper.GetDuration("updates.drain_timeout"); timeout > 0 {
+		return timeout
+	}
+
+	return defaultDrainTimeout
+}
+
+func waitForShutdown() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	sig := <-signals
+	log.Printf("[main] Got %s, shutting down\n", sig)
+}
+
 func getFlags() Flags {
-	var config, port, cert, key string
+	var config, port, cert, key, mode string
 	flag.StringVar(&config, "config", "./config.json", "The server configuration file")
 	flag.StringVar(&port, "port", "8080", "The port to run on")
 	flag.StringVar(&cert, "cert", "", "SSL Certificate")
 	flag.StringVar(&key, "key", "", "Private key")
+	flag.StringVar(&mode, "mode", webhookMode, "How to receive updates, either \"webhook\" or \"poll\"")
 	flag.Parse()
 
 	return Flags{
@@ -65,26 +204,87 @@ func getFlags() Flags {
 		Port:   port,
 		Cert:   cert,
 		Key:    key,
+		Mode:   mode,
 	}
 }
 
-func createServices(telegramToken string, yelpKey string) *Services {
+func createServices(telegramToken string, yelpKey string, sessions service.SessionStore) (*Services, error) {
 	yelpService := service.NewYelpService(yelpKey)
-	telegramService := service.NewTelegramService(telegramToken, yelpService)
+	if cacheSize := viper.GetInt("yelp.cache.size"); cacheSize > 0 {
+		yelpService = service.NewCachingYelpService(yelpService, cacheSize, viper.GetDuration("yelp.cache.ttl"), viper.GetDuration("yelp.cache.business_ttl"))
+	}
+
+	placeProvider, err := createPlaceProvider(yelpService)
+	if err != nil {
+		return nil, err
+	}
+
+	telegramService := service.NewTelegramService(telegramToken, placeProvider, sessions)
 
 	return &Services{
 		TelegramService: telegramService,
 		YelpService:     yelpService,
+		PlaceProvider:   placeProvider,
+	}, nil
+}
+
+// createPlaceProvider creates each provider listed in "places.providers",
+// searching them all together when there is more than one.
+func createPlaceProvider(yelpService service.YelpService) (service.PlaceProvider, error) {
+	providerTypes := viper.GetStringSlice("places.providers")
+	if len(providerTypes) == 0 {
+		providerTypes = []string{service.YelpPlaceProvider}
+	}
+
+	providers := []service.PlaceProvider{}
+	for _, providerType := range providerTypes {
+		provider, err := service.NewPlaceProvider(providerType, yelpService, viper.GetString("places.geojson_path"))
+		if err != nil {
+			return nil, err
+		}
+
+		providers = append(providers, provider)
 	}
+
+	if len(providers) == 1 {
+		return providers[0], nil
+	}
+
+	return service.NewFederatedPlaceProvider(providers, viper.GetDuration("places.search_timeout")), nil
 }
 
-func createRoutes(services *Services) *mux.Router {
+// webhookSecretToken returns the configured secret token, or makes up a new
+// one each time the bot starts since the webhook is registered again anyway.
+func webhookSecretToken() (string, error) {
+	if secretToken := viper.GetString("telegram.webhook.secret_token"); secretToken != "" {
+		return secretToken, nil
+	}
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(token), nil
+}
+
+func createRoutes(services *Services, queue *service.UpdateQueue, secretToken string) (*mux.Router, error) {
 	r := mux.NewRouter()
 
+	messageHandler := handler.ReceiveMessageHandler(queue, secretToken)
+	if viper.GetBool("telegram.webhook.telegram_networks_only") {
+		var err error
+		messageHandler, err = handler.AllowNetworks(handler.TelegramNetworks, viper.GetBool("telegram.webhook.trust_forwarded_for"), messageHandler)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	r.HandleFunc("/health", handler.HealthHandler()).Methods("GET")
-	r.HandleFunc("/message", handler.ReceiveMessageHandler(services.TelegramService)).Methods("POST")
+	r.HandleFunc("/metrics", handler.MetricsHandler()).Methods("GET")
+	r.HandleFunc("/message", messageHandler).Methods("POST")
 
-	return r
+	return r, nil
 }
 
 func createServer(port string, routes *mux.Router) *http.Server {
@@ -1,14 +1,93 @@
 package model
 
+import "time"
+
 type UserLocationInfo struct {
-	Location       Coordinates
-	LastCommand    string
-	LastSearchTerm string
+	// The last location the user shared and when, reused for nearby searches
+	// while it's recent.
+	Location        Coordinates `json:"location"`
+	LocationUpdated time.Time   `json:"location_updated,omitempty"`
+
+	LastCommand    string `json:"last_command"`
+	LastSearchTerm string `json:"last_search_term"`
+
+	// Where the last search was run, either a user given location or the
+	// coordinates they shared, so later pages of results can be fetched.
+	LastSearchLocation    string        `json:"last_search_location,omitempty"`
+	LastSearchCoordinates Coordinates   `json:"last_search_coordinates,omitempty"`
+	LastSearchOptions     SearchOptions `json:"last_search_options,omitempty"`
+	// Counts the user's searches, so buttons on an older search's results
+	// can tell they no longer match the last search.
+	LastSearchID int `json:"last_search_id,omitempty"`
+
+	// The last message's parsed query, kept so a command waiting on the
+	// user's location can be finished when they share it.
+	LastQuery Query `json:"last_query,omitempty"`
+
+	// Places the user named with /setplace, by name. These are kept in the
+	// session of the user rather than the chat, so they work in every chat.
+	SavedPlaces map[string]SavedPlace `json:"saved_places,omitempty"`
+
+	// Places the user saved from search results, oldest first. Like saved
+	// places these are kept in the user's session.
+	Favorites []FavoritePlace `json:"favorites,omitempty"`
+}
+
+// FavoritePlace is what's needed to look a place up again, and to show it if
+// that fails.
+type FavoritePlace struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// SavedPlace is either a location the user shared or an address they gave.
+type SavedPlace struct {
+	Coordinates Coordinates `json:"coordinates,omitempty"`
+	Address     string      `json:"address,omitempty"`
+}
+
+// Copy returns a copy of the session sharing no maps or slices with it, so a
+// store can hand out sessions without callers changing what it holds.
+func (info UserLocationInfo) Copy() UserLocationInfo {
+	info.LastSearchOptions = info.LastSearchOptions.Copy()
+	info.LastQuery.Options = info.LastQuery.Options.Copy()
+
+	if info.SavedPlaces != nil {
+		savedPlaces := make(map[string]SavedPlace, len(info.SavedPlaces))
+		for name, place := range info.SavedPlaces {
+			savedPlaces[name] = place
+		}
+
+		info.SavedPlaces = savedPlaces
+	}
+
+	info.Favorites = append([]FavoritePlace(nil), info.Favorites...)
+
+	return info
 }
 
 func (info UserLocationInfo) IsEmpty() bool {
 	return info.Location.Latitude == 0 &&
 		info.Location.Longitude == 0 &&
 		info.LastCommand == "" &&
-		info.LastSearchTerm == ""
+		info.LastSearchTerm == "" &&
+		info.LastSearchLocation == "" &&
+		info.LastSearchCoordinates.Latitude == 0 &&
+		info.LastSearchCoordinates.Longitude == 0 &&
+		len(info.SavedPlaces) == 0 &&
+		len(info.Favorites) == 0
+}
+
+// Query is a parsed user message. Command is empty when the message isn't a
+// command, and BotMention is the username in commands like "/search@MyBot".
+// Term, Location, Nearby and Options only apply to searches.
+type Query struct {
+	Command    string        `json:"command,omitempty"`
+	BotMention string        `json:"bot_mention,omitempty"`
+	Arguments  string        `json:"arguments,omitempty"`
+	Term       string        `json:"term,omitempty"`
+	Location   string        `json:"location,omitempty"`
+	Nearby     bool          `json:"nearby,omitempty"`
+	Options    SearchOptions `json:"options,omitempty"`
 }
@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// Place is a business from any place provider, normalized so the bot doesn't
+// need to know where it came from.
+type Place struct {
+	ID          string      `json:"id"`
+	Provider    string      `json:"provider"`
+	Name        string      `json:"name"`
+	URL         string      `json:"url"`
+	Rating      float64     `json:"rating"`
+	ReviewCount int         `json:"review_count"`
+	Price       string      `json:"price"`
+	Phone       string      `json:"phone"`
+	Categories  []string    `json:"categories"`
+	Address     []string    `json:"address"`
+	Coordinates Coordinates `json:"coordinates"`
+	// Distance in meters from where the search was centered, 0 when unknown
+	Distance float64 `json:"distance"`
+	ImageURL string  `json:"image_url"`
+}
+
+type PlaceSearchResult struct {
+	Total  int     `json:"total"`
+	Places []Place `json:"places"`
+}
+
+// PlaceDetails adds what a provider knows about a single place beyond what
+// it returns in searches. Hours is empty when the opening hours are unknown.
+type PlaceDetails struct {
+	Place
+	Photos    []string   `json:"photos"`
+	Hours     []OpenTime `json:"hours"`
+	IsOpenNow bool       `json:"is_open_now"`
+}
+
+type PlaceReview struct {
+	Rating  int       `json:"rating"`
+	Text    string    `json:"text"`
+	Author  string    `json:"author"`
+	Created time.Time `json:"created"`
+	URL     string    `json:"url"`
+}
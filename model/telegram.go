@@ -1,10 +1,5 @@
 package model
 
-type BotInfoResponseWrapper struct {
-	OK     bool    `json:"ok"`
-	Result BotInfo `json:"result"`
-}
-
 type BotInfo struct {
 	ID       int64  `json:"id"`
 	IsBot    bool   `json:"is_bot"`
@@ -12,15 +7,45 @@ type BotInfo struct {
 	Username string `json:"username"`
 }
 
-type SetWebhookResponse struct {
-	OK          bool   `json:"ok"`
-	Result      bool   `json:"result"`
-	Description string `json:"description"`
+// SetWebhook asks Telegram to send updates to URL. When SecretToken is set
+// Telegram sends it in the X-Telegram-Bot-Api-Secret-Token header of every
+// update, so the bot can tell the updates really came from Telegram.
+type SetWebhook struct {
+	URL         string `json:"url"`
+	SecretToken string `json:"secret_token,omitempty"`
+}
+
+type WebhookInfo struct {
+	URL                  string `json:"url"`
+	HasCustomCertificate bool   `json:"has_custom_certificate"`
+	PendingUpdateCount   int    `json:"pending_update_count"`
+	LastErrorDate        int64  `json:"last_error_date,omitempty"`
+	LastErrorMessage     string `json:"last_error_message,omitempty"`
+	MaxConnections       int    `json:"max_connections,omitempty"`
+}
+
+// GetUpdates long polls for updates with an ID of at least Offset, waiting up
+// to Timeout seconds for one to arrive.
+type GetUpdates struct {
+	Offset  int64 `json:"offset,omitempty"`
+	Timeout int   `json:"timeout,omitempty"`
 }
 
 type ReceivedMessage struct {
-	UpdateID int64       `json:"update_id"`
-	Message  MessageInfo `json:"message"`
+	UpdateID      int64          `json:"update_id"`
+	Message       MessageInfo    `json:"message"`
+	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
+	Poll          *Poll          `json:"poll,omitempty"`
+	PollAnswer    *PollAnswer    `json:"poll_answer,omitempty"`
+}
+
+// CallbackQuery is sent when a user presses an inline keyboard button. Message
+// is the bot's message the button was attached to.
+type CallbackQuery struct {
+	ID      string      `json:"id"`
+	From    UserInfo    `json:"from"`
+	Message MessageInfo `json:"message"`
+	Data    string      `json:"data"`
 }
 
 type MessageInfo struct {
@@ -32,6 +57,8 @@ type MessageInfo struct {
 	ForwardFrom ForwarderInfo `json:"forward_from,omitempty"`
 	ForwardDate int64         `json:"forward_date,omitempty"`
 	Location    Coordinates   `json:"location"`
+	// ReplyToMessage is only filled in one level deep by Telegram
+	ReplyToMessage *MessageInfo `json:"reply_to_message,omitempty"`
 }
 
 type ChatInfo struct {
@@ -55,28 +82,23 @@ type ForwarderInfo struct {
 	ID        int64  `json:"id"`
 }
 
-type SendMessageResponse struct {
-	OK          bool              `json:"ok"`
-	Result      SendMessageResult `json:"result"`
-	Description string            `json:"description,omitempty"`
-}
-
 type SendMessageResult struct {
-	MessageID int      `json:"message_id"`
+	MessageID int64    `json:"message_id"`
 	From      UserInfo `json:"from"`
 	Chat      ChatInfo `json:"chat"`
 	Date      int64    `json:"date"`
 	Text      string   `json:"text"`
+	Poll      *Poll    `json:"poll,omitempty"`
 }
 
 type Message struct {
-	ChatID                int64       `json:"chat_id"`
-	Text                  string      `json:"text"`
-	ParseMode             string      `json:"parse_mode,omitempty"`
-	DisableWebPagePreview bool        `json:"disable_web_page_preview,omitempty"`
-	DisableNotification   bool        `json:"disalbe_notification,omitempty"`
-	ReplyToMessageID      int64       `json:"reply_to_message_id,omitempty"`
-	ReplyMarkup           ReplyMarkup `json:"reply_markup,omitempty"`
+	ChatID                int64        `json:"chat_id"`
+	Text                  string       `json:"text"`
+	ParseMode             string       `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool         `json:"disable_web_page_preview,omitempty"`
+	DisableNotification   bool         `json:"disalbe_notification,omitempty"`
+	ReplyToMessageID      int64        `json:"reply_to_message_id,omitempty"`
+	ReplyMarkup           *ReplyMarkup `json:"reply_markup,omitempty"`
 }
 
 func NewMessage(chatID int64, text string) *Message {
@@ -86,11 +108,91 @@ func NewMessage(chatID int64, text string) *Message {
 	}
 }
 
+type SendLocation struct {
+	ChatID           int64        `json:"chat_id"`
+	Latitude         float64      `json:"latitude"`
+	Longitude        float64      `json:"longitude"`
+	ReplyToMessageID int64        `json:"reply_to_message_id,omitempty"`
+	ReplyMarkup      *ReplyMarkup `json:"reply_markup,omitempty"`
+}
+
+// SendPhoto sends a photo Telegram can fetch itself, Photo is either a URL or
+// the file ID of a photo Telegram already has.
+type SendPhoto struct {
+	ChatID           int64        `json:"chat_id"`
+	Photo            string       `json:"photo"`
+	Caption          string       `json:"caption,omitempty"`
+	ParseMode        string       `json:"parse_mode,omitempty"`
+	ReplyToMessageID int64        `json:"reply_to_message_id,omitempty"`
+	ReplyMarkup      *ReplyMarkup `json:"reply_markup,omitempty"`
+}
+
+// SendPoll posts a poll. Bots are sent the poll's state as it changes for
+// polls they sent, with each user's answer too when it isn't anonymous.
+type SendPoll struct {
+	ChatID           int64             `json:"chat_id"`
+	Question         string            `json:"question"`
+	Options          []InputPollOption `json:"options"`
+	IsAnonymous      bool              `json:"is_anonymous"`
+	ReplyToMessageID int64             `json:"reply_to_message_id,omitempty"`
+}
+
+type InputPollOption struct {
+	Text string `json:"text"`
+}
+
+// StopPoll closes a poll the bot sent, Telegram answers with its final state.
+type StopPoll struct {
+	ChatID    int64 `json:"chat_id"`
+	MessageID int64 `json:"message_id"`
+}
+
+type Poll struct {
+	ID              string       `json:"id"`
+	Question        string       `json:"question"`
+	Options         []PollOption `json:"options"`
+	TotalVoterCount int          `json:"total_voter_count"`
+	IsClosed        bool         `json:"is_closed"`
+	IsAnonymous     bool         `json:"is_anonymous"`
+}
+
+type PollOption struct {
+	Text       string `json:"text"`
+	VoterCount int    `json:"voter_count"`
+}
+
+// PollAnswer is a user's vote in a poll that isn't anonymous. OptionIDs is
+// empty when they retract their vote.
+type PollAnswer struct {
+	PollID    string   `json:"poll_id"`
+	User      UserInfo `json:"user"`
+	OptionIDs []int    `json:"option_ids"`
+}
+
+// EditMessageText replaces the text and inline keyboard of a message the bot
+// already sent.
+type EditMessageText struct {
+	ChatID                int64        `json:"chat_id"`
+	MessageID             int64        `json:"message_id"`
+	Text                  string       `json:"text"`
+	ParseMode             string       `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool         `json:"disable_web_page_preview,omitempty"`
+	ReplyMarkup           *ReplyMarkup `json:"reply_markup,omitempty"`
+}
+
+type AnswerCallbackQuery struct {
+	CallbackQueryID string `json:"callback_query_id"`
+	Text            string `json:"text,omitempty"`
+}
+
+// ReplyMarkup holds either a custom reply keyboard or an inline keyboard,
+// Telegram rejects markup that sets both.
 type ReplyMarkup struct {
-	Keyboard        [][]KeyboardButton `json:"keyboard"`
-	ResizeKeyboard  bool               `json:"resize_keyboard,omitempty"`
-	OneTimeKeyboard bool               `json:"one_time_keyboard,omitempty"`
-	Selective       bool               `json:"selective,omitempty"`
+	Keyboard        [][]KeyboardButton       `json:"keyboard,omitempty"`
+	InlineKeyboard  [][]InlineKeyboardButton `json:"inline_keyboard,omitempty"`
+	ResizeKeyboard  bool                     `json:"resize_keyboard,omitempty"`
+	OneTimeKeyboard bool                     `json:"one_time_keyboard,omitempty"`
+	Selective       bool                     `json:"selective,omitempty"`
 }
 
 type KeyboardButton struct {
@@ -98,3 +200,25 @@ type KeyboardButton struct {
 	RequestContact  bool   `json:"request_contact,omitempty"`
 	RequestLocation bool   `json:"request_location,omitempty"`
 }
+
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	URL          string `json:"url,omitempty"`
+	CallbackData string `json:"callback_data,omitempty"`
+}
+
+type BotCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+type SetMyCommands struct {
+	Commands []BotCommand `json:"commands"`
+}
+
+// ResponseParameters explain why a failed request failed, and what to do
+// about it.
+type ResponseParameters struct {
+	MigrateToChatID int64 `json:"migrate_to_chat_id,omitempty"`
+	RetryAfter      int   `json:"retry_after,omitempty"`
+}
@@ -6,6 +6,33 @@ type SearchResponse struct {
 	Region     Region     `json:"region"`
 }
 
+// SearchOptions narrows a business search. Zero values leave the option to
+// Yelp's default.
+type SearchOptions struct {
+	Offset int `json:"offset,omitempty"`
+	Limit  int `json:"limit,omitempty"`
+
+	// Price tiers from 1 ($) to 4 ($$$$)
+	Price   []int `json:"price,omitempty"`
+	OpenNow bool  `json:"open_now,omitempty"`
+	// Radius in meters, Yelp allows at most 40000
+	Radius int `json:"radius,omitempty"`
+	// One of best_match, rating, review_count or distance
+	SortBy     string   `json:"sort_by,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	// Yelp attributes such as hot_and_new or deals
+	Attributes []string `json:"attributes,omitempty"`
+}
+
+// Copy returns a copy of the options sharing no slices with them.
+func (options SearchOptions) Copy() SearchOptions {
+	options.Price = append([]int(nil), options.Price...)
+	options.Categories = append([]string(nil), options.Categories...)
+	options.Attributes = append([]string(nil), options.Attributes...)
+
+	return options
+}
+
 type Business struct {
 	Rating       float64     `json:"rating"`
 	Price        string      `json:"price"`
@@ -23,6 +50,53 @@ type Business struct {
 	Transactions []string    `json:"transactions"`
 }
 
+// BusinessDetails is the fuller description of a single business returned by
+// /businesses/{id}.
+type BusinessDetails struct {
+	Business
+	DisplayPhone string   `json:"display_phone"`
+	Photos       []string `json:"photos"`
+	Hours        []Hours  `json:"hours"`
+}
+
+type Hours struct {
+	HoursType string     `json:"hours_type"`
+	IsOpenNow bool       `json:"is_open_now"`
+	Open      []OpenTime `json:"open"`
+}
+
+// OpenTime is one opening period. Day is 0 for Monday through 6 for Sunday
+// and Start and End are 24 hour "HHMM" times.
+type OpenTime struct {
+	Day         int    `json:"day"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	IsOvernight bool   `json:"is_overnight"`
+}
+
+type ReviewsResponse struct {
+	Total   int      `json:"total"`
+	Reviews []Review `json:"reviews"`
+}
+
+// Review is an excerpt of a Yelp review. TimeCreated is formatted as
+// "2006-01-02 15:04:05" in the business's local time.
+type Review struct {
+	ID          string     `json:"id"`
+	Rating      int        `json:"rating"`
+	Text        string     `json:"text"`
+	TimeCreated string     `json:"time_created"`
+	URL         string     `json:"url"`
+	User        ReviewUser `json:"user"`
+}
+
+type ReviewUser struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	ProfileURL string `json:"profile_url"`
+	ImageURL   string `json:"image_url"`
+}
+
 type Category struct {
 	Alias string `json:"alias"`
 	Title string `json:"title"`
@@ -36,6 +110,8 @@ type Location struct {
 	Address3 string `json:"address3"`
 	State    string `json:"state"`
 	ZipCode  string `json:"zip_code"`
+
+	DisplayAddress []string `json:"display_address"`
 }
 
 type Region struct {
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -8,248 +9,445 @@ import (
 	"strings"
 	"time"
 
+	"github.com/spf13/viper"
 	"github.com/zachvanuum/FoodHelperBot/model"
 )
 
 const (
 	// Recognized user commands
-	HelpCommand   = "/help"
-	RandomCommand = "/random"
-	SearchCommand = "/search"
-	StartCommand  = "/start"
+	DetailsCommand        = "/details"
+	FavoritesCommand      = "/favorites"
+	ForgetLocationCommand = "/forgetlocation"
+	HelpCommand           = "/help"
+	LunchPollCommand      = "/lunchpoll"
+	RandomCommand         = "/random"
+	PlacesCommand         = "/places"
+	ReviewsCommand        = "/reviews"
+	SearchCommand         = "/search"
+	SetPlaceCommand       = "/setplace"
+	StartCommand          = "/start"
+	UnfavoriteCommand     = "/unfavorite"
 
 	// Response messages
-	BadCommandResponse   = "Valid queries start with \"/\", for example \"/search <term>\" will search for businesses near you."
-	DefaultResponse      = "Sorry, but I don't know how to answer that query."
-	FailedResponse       = "Sorry, I was unable to perform that search."
-	greetingStringFormat = `Hello, my name is %s. You can contact me by messaging @%s. 
-	Accepted requests are:
-		"/search <cuisine/business> in <location>",
-		"/search <cuisine/business> nearby/near me", and
-		"/random"
-	To see these again send "/start" or "/help".`
-	LocationResponse = "Please provide your location so that I can search for businesses near you."
-	ThanksResponse   = "Thank you!"
+	CooldownResponseFormat               = "Slow down a little! Please wait %ds before asking again."
+	BadCommandResponse                   = "Valid queries start with \"/\", for example \"/search <term>\" will search for businesses near you."
+	DefaultResponse                      = "Sorry, but I don't know how to answer that query."
+	DetailsUsageResponse                 = "Send \"/details <number>\" with the number of a result from your last search."
+	ReviewsUsageResponse                 = "Send \"/reviews <number>\" with the number of a result from your last search."
+	ReviewsUnavailableResponse           = "Sorry, I don't have reviews for that place."
+	FailedResponse                       = "Sorry, I was unable to perform that search."
+	SearchUnavailableResponse            = "Sorry, searching is temporarily unavailable, please try again later."
+	SearchBusyResponse                   = "Sorry, I'm getting a lot of requests right now, please try again in a moment."
+	SearchMisconfiguredResponse          = "Sorry, searching isn't set up properly right now, please let my owner know."
+	UpstreamUnavailableResponse          = "Sorry, the search service isn't responding right now, please try again later."
+	InvalidLocationResponse              = "Sorry, I couldn't find that location. Try a city, neighborhood or address."
+	greetingStringFormat                 = "Hello, my name is %s. You can contact me by messaging @%s.\nAccepted requests are:\n%s\nTo see these again send \"/start\" or \"/help\"."
+	LocationResponse                     = "Please provide your location so that I can search for businesses near you."
+	LocationSavedResponse                = "Thanks! I'll search near here when you ask for places nearby."
+	LocationForgottenResponse            = "I've forgotten your location."
+	SetPlaceUsageResponse                = "Send \"/setplace <name>\" to save your location, or \"/setplace <name> <address>\" to save an address. Names can use letters, numbers, \"-\" and \"_\"."
+	PlaceSavedResponseFormat             = "Saved @%s, search around it with \"/search <term> in @%s\"."
+	NoSavedPlacesResponse                = "You haven't saved any places yet, send \"/setplace <name>\" to save one."
+	UnknownPlaceResponseFormat           = "You haven't saved a place called @%s, send \"/places\" to see the ones you have."
+	NoFavoritesResponse                  = "You don't have any favorites yet, press \"⭐ Save\" on a search result to add one."
+	UnfavoriteUsageResponse              = "Send \"/unfavorite <number>\" with the number of a place in \"/favorites\"."
+	UnfavoritedResponseFormat            = "Removed %s from your favorites."
+	FavoriteSavedResponseFormat          = "Saved %s to your favorites."
+	FavoriteExistsResponseFormat         = "%s is already one of your favorites."
+	TooManyFavoritesResponseFormat       = "You already have %d favorites, remove one with /unfavorite first."
+	LunchPollQuestionFormat              = "Where should we go for %s?"
+	LunchPollTooFewResultsResponseFormat = "Sorry, I couldn't find enough places searching for %s to make a poll."
+	LunchPollFailedResponse              = "Sorry, I couldn't start the poll."
+	LunchPollNoVotesResponseFormat       = "Nobody voted on where to go for %s."
+	LunchPollWinnerResponseFormat        = "The winner is %s with %d of %d votes!\n\n"
+	NoResultsResponseFormat              = "Sorry, I couldn't find anything searching for %s."
+	ThanksResponse                       = "Thank you!"
 
 	LocationKeyboardText = "Provide Location"
 
-	searchLocationDelimiter     = " in "
-	userLocationDelimiterNearMe = " near me"
-	userLocationDelimiterNearby = " nearby"
+	// Search grammar keywords, see parseQuery
+	searchLocationKeyword = "in"
+	nearbyKeyword         = "nearby"
+	nearKeyword           = "near"
+	meKeyword             = "me"
 )
 
 type BotService interface {
 	CreateResponseMessage(message model.ReceivedMessage) *model.Message
+	CreateCallbackResponse(message model.ReceivedMessage) (*model.EditMessageText, *model.AnswerCallbackQuery)
+	HandlePoll(poll model.Poll)
+	Commands() []model.BotCommand
 	Greeting() string
 }
 
+// defaultLocationMaxAge is used when session.location_max_age isn't set.
+const defaultLocationMaxAge = 30 * time.Minute
+
 type botService struct {
-	ID          int64
-	Name        string
-	Username    string
-	YelpService YelpService
-	UsersCache  map[int64]*model.UserLocationInfo
+	ID           int64
+	Name         string
+	Username     string
+	Places       PlaceProvider
+	Sessions     SessionStore
+	messenger    Messenger
+	registry     *CommandRegistry
+	updates      *chatSequencer
+	throttle     updateThrottle
+	sessionLocks *sessionLocks
+
+	// How long a shared location is reused before asking for it again
+	locationMaxAge time.Duration
+
+	polls             *lunchPolls
+	lunchPollOptions  int
+	lunchPollDuration time.Duration
 }
 
-func NewTelegramBot(info model.BotInfo, yelp YelpService) BotService {
-	return &botService{
-		ID:          info.ID,
-		Name:        info.Name,
-		Username:    info.Username,
-		YelpService: yelp,
-		UsersCache:  make(map[int64]*model.UserLocationInfo),
+// NewTelegramBot makes the bot, which replies to updates itself and uses
+// messenger to send anything else.
+func NewTelegramBot(info model.BotInfo, places PlaceProvider, sessions SessionStore, messenger Messenger) BotService {
+	bot := &botService{
+		ID:           info.ID,
+		Name:         info.Name,
+		Username:     info.Username,
+		Places:       places,
+		Sessions:     sessions,
+		messenger:    messenger,
+		registry:     NewCommandRegistry(),
+		updates:      newChatSequencer(),
+		sessionLocks: newSessionLocks(),
+		throttle: newUpdateThrottle(
+			viper.GetFloat64("throttle.user.per_minute"),
+			viper.GetInt("throttle.user.burst"),
+			viper.GetFloat64("throttle.chat.per_minute"),
+			viper.GetInt("throttle.chat.burst"),
+		),
+		locationMaxAge:    viper.GetDuration("session.location_max_age"),
+		polls:             newLunchPolls(),
+		lunchPollOptions:  viper.GetInt("lunch_poll.options"),
+		lunchPollDuration: viper.GetDuration("lunch_poll.duration"),
+	}
+
+	if bot.lunchPollOptions < minPollOptions || bot.lunchPollOptions > maxPollOptions {
+		bot.lunchPollOptions = defaultLunchPollOptions
+	}
+
+	if bot.lunchPollDuration <= 0 {
+		bot.lunchPollDuration = defaultLunchPollDuration
 	}
+
+	if bot.locationMaxAge <= 0 {
+		bot.locationMaxAge = defaultLocationMaxAge
+	}
+
+	bot.registerCommands()
+
+	return bot
 }
 
+// CreateResponseMessage is safe to call from many goroutines. Updates from the
+// same chat are handled one at a time, in update ID order, so each reads the
+// session the previous one left behind. It returns nil when the message wasn't
+// meant for the bot and should go unanswered.
 func (svc botService) CreateResponseMessage(message model.ReceivedMessage) *model.Message {
-	command, remaining := splitUserMessageToQuery(message.Message.Text)
+	var response *model.Message
 
-	log.Printf("[CreateResponseMessage] User query: %s, remaining message: \"%s\"", command, remaining)
+	svc.updates.Do(message.Message.Chat.ID, message.UpdateID, func() {
+		response = svc.createResponseMessage(message)
+	})
 
-	response := model.NewMessage(message.Message.Chat.ID, "")
+	return response
+}
 
-	switch command {
-	case StartCommand, HelpCommand:
-		response.Text = svc.Greeting()
-	case SearchCommand:
-		term := getUserSearchTerm(remaining)
-		svc.updateUserLastSearchTerm(message.Message.Chat.ID, term)
+func (svc botService) createResponseMessage(message model.ReceivedMessage) *model.Message {
+	chatID := message.Message.Chat.ID
+	sessionKey := chatSessionKey(message.Message.Chat, message.Message.From.ID)
+	query := parseQuery(message.Message.Text)
 
-		if isUserLocationSearchQuery(remaining) {
-			log.Printf("[createResponseMessage] User search term: %s", term)
-			addLocationKeyboardMarkup(response)
-			response.Text = LocationResponse
-			break
-		}
+	if !svc.isAddressedToBot(message, query, sessionKey) {
+		return nil
+	}
 
-		location := getUserSepcifiedSearchLocation(remaining)
-		log.Printf("[createResponseMessage] User search term: %s, user search location: %s", term, location)
+	log.Printf("[CreateResponseMessage] User query: %s, arguments: \"%s\"", query.Command, query.Arguments)
 
-		searchResults, err := svc.YelpService.SearchByLocation(term, location)
-		if err != nil {
-			log.Printf("[createResponseMessage] %s", err.Error())
+	response := model.NewMessage(chatID, "")
+	response.ReplyToMessageID = message.Message.MessageID
 
-			response.Text = FailedResponse
-		} else {
-			svc.createSearchResponse(response, searchResults)
-		}
-	case RandomCommand:
-		addLocationKeyboardMarkup(response)
-		response.Text = LocationResponse
-		svc.updateUserLastSearchTerm(message.Message.Chat.ID, getRandomCuisine())
-	default:
-		if isProvidingLocation(message) &&
-			svc.UsersCache[message.Message.Chat.ID].LastCommand == SearchCommand ||
-			svc.UsersCache[message.Message.Chat.ID].LastCommand == RandomCommand {
-			log.Printf("[createResponseMessage] Got user's location - Chat ID: %d, Message ID: %d, Location: %f, %f",
-				message.Message.Chat.ID,
-				message.Message.MessageID,
-				message.Message.Location.Latitude,
-				message.Message.Location.Longitude,
-			)
-
-			searchResults, err := svc.YelpService.SearchByCoordinates(svc.UsersCache[message.Message.Chat.ID].LastSearchTerm, message.Message.Location.Latitude, message.Message.Location.Longitude)
-			if err != nil {
-				log.Printf("[createResponseMessage] %s", err.Error())
-
-				response.Text = FailedResponse
-			} else {
-				svc.createSearchResponse(response, searchResults)
-			}
+	if ok, wait := svc.throttle.Allow(message.Message.From.ID, chatID); !ok {
+		log.Printf("[createResponseMessage] Throttled user %d in chat %d for %s", message.Message.From.ID, chatID, wait)
+		response.Text = cooldownResponse(wait)
+		return response
+	}
 
+	request := CommandRequest{
+		Message: message,
+		Query:   query,
+		Session: sessionKey,
+	}
+
+	// Remembered so a command waiting for the user's location can be
+	// finished when they share it
+	lastQuery := query
+
+	command, ok := svc.registry.Lookup(query.Command)
+	switch {
+	case ok && command.NeedsLocation(query):
+		location, recent := svc.recentLocation(sessionKey)
+		if !recent {
+			addLocationKeyboardMarkup(response)
+			response.Text = LocationResponse
 			break
 		}
 
-		if !strings.Contains(command, "/") {
-			response.Text = BadCommandResponse
-		} else {
-			response.Text = DefaultResponse
+		request.Location = &location
+		command.Handle(request, response)
+		lastQuery = model.Query{}
+	case ok:
+		command.Handle(request, response)
+	case query.Command == "" && isProvidingLocation(message):
+		log.Printf("[createResponseMessage] Got user's location - Chat ID: %d, Message ID: %d, Location: %f, %f",
+			chatID,
+			message.Message.MessageID,
+			message.Message.Location.Latitude,
+			message.Message.Location.Longitude,
+		)
+
+		session := svc.updateUserLocation(sessionKey, message.Message.Location)
+		request.Location = &message.Message.Location
+
+		command, ok = svc.waitingForLocation(session)
+		switch {
+		case ok:
+			request.Query = session.LastQuery
+			command.Handle(request, response)
+		case hasLastSearch(session):
+			// Sharing a new location without a command waiting for it runs
+			// the last search again here.
+			svc.search(sessionKey, response, session.LastSearchTerm, "", message.Message.Location, session.LastSearchOptions)
+		default:
+			response.Text = LocationSavedResponse
 		}
+	case query.Command == "":
+		response.Text = BadCommandResponse
+	default:
+		response.Text = DefaultResponse
 	}
 
-	svc.updateUserLastCommand(message.Message.Chat.ID, command)
+	svc.updateUserLastQuery(sessionKey, lastQuery)
 
-	response.ReplyToMessageID = message.Message.MessageID
 	return response
 }
 
-func (svc botService) Greeting() string {
-	return fmt.Sprintf(greetingStringFormat, svc.Name, svc.Username)
-}
+// isAddressedToBot reports whether the bot should answer a message. Private
+// chats are all meant for the bot, but in groups it only answers its own
+// commands, and locations shared by a user it asked for one, so it stays out
+// of the conversation. A command mentioning another bot is never answered.
+func (svc botService) isAddressedToBot(message model.ReceivedMessage, query model.Query, sessionKey SessionKey) bool {
+	if query.BotMention != "" {
+		return strings.EqualFold(query.BotMention, svc.Username)
+	}
 
-func (svc botService) updateUserLocation(chatID int64, latitude float64, longitude float64) {
-	if _, ok := svc.UsersCache[chatID]; ok {
-		svc.UsersCache[chatID].Location.Latitude = latitude
-		svc.UsersCache[chatID].Location.Longitude = longitude
-	} else {
-		svc.UsersCache[chatID] = &model.UserLocationInfo{
-			Location: model.Coordinates{
-				Latitude:  latitude,
-				Longitude: longitude,
-			},
-		}
+	if !isGroupChat(message.Message.Chat) {
+		return true
 	}
-}
 
-func (svc botService) updateUserLastCommand(chatID int64, command string) {
-	if _, ok := svc.UsersCache[chatID]; ok {
-		svc.UsersCache[chatID].LastCommand = command
-	} else {
-		svc.UsersCache[chatID] = &model.UserLocationInfo{
-			LastCommand: command,
+	if query.Command == "" {
+		if !isProvidingLocation(message) {
+			return false
 		}
+
+		_, waiting := svc.waitingForLocation(svc.getUserSession(sessionKey))
+		return waiting
 	}
+
+	// Other bots in the group may have commands without being mentioned
+	_, ok := svc.registry.Lookup(query.Command)
+	return ok
 }
 
-func (svc botService) updateUserLastSearchTerm(chatID int64, term string) {
-	if _, ok := svc.UsersCache[chatID]; ok {
-		svc.UsersCache[chatID].LastSearchTerm = term
-	} else {
-		svc.UsersCache[chatID] = &model.UserLocationInfo{
-			LastSearchTerm: term,
-		}
+// waitingForLocation returns the command the user sent last if it's waiting
+// for them to share their location.
+func (svc botService) waitingForLocation(session model.UserLocationInfo) (Command, bool) {
+	command, ok := svc.registry.Lookup(session.LastCommand)
+	if !ok || !command.NeedsLocation(session.LastQuery) {
+		return nil, false
 	}
+
+	return command, true
+}
+
+func (svc botService) Commands() []model.BotCommand {
+	return svc.registry.BotCommands()
 }
 
-func splitUserMessageToQuery(text string) (string, string) {
-	splitText := strings.Split(text, " ")
-	command := splitText[0]
-	remaining := strings.Join(splitText[1:len(splitText)], " ")
+func (svc botService) Greeting() string {
+	return fmt.Sprintf(greetingStringFormat, svc.Name, svc.Username, svc.registry.Help())
+}
+
+func (svc botService) getUserSession(key SessionKey) model.UserLocationInfo {
+	session, err := svc.Sessions.Get(key)
+	if err != nil {
+		log.Printf("[getUserSession] %s", err.Error())
+	}
 
-	return command, remaining
+	return session
 }
 
-func isUserLocationSearchQuery(query string) bool {
-	userLocationSearchDelimitters := []string{userLocationDelimiterNearMe, userLocationDelimiterNearby}
+// updateUserSession changes a session with update, which must not hold on to
+// the session it's given. Concurrent updates to the same session run one at a
+// time so none of them are lost.
+func (svc botService) updateUserSession(key SessionKey, update func(*model.UserLocationInfo)) {
+	svc.sessionLocks.Do(key, func() {
+		session := svc.getUserSession(key)
+		update(&session)
 
-	for _, delimitter := range userLocationSearchDelimitters {
-		if strings.Contains(query, delimitter) {
-			return true
+		if err := svc.Sessions.Put(key, session); err != nil {
+			log.Printf("[updateUserSession] %s", err.Error())
 		}
-	}
+	})
+}
+
+// updateUserLocation stores the location the user shared and returns the
+// updated session.
+func (svc botService) updateUserLocation(key SessionKey, location model.Coordinates) model.UserLocationInfo {
+	var updated model.UserLocationInfo
 
-	return false
+	svc.updateUserSession(key, func(session *model.UserLocationInfo) {
+		session.Location = location
+		session.LocationUpdated = time.Now()
+		updated = *session
+	})
+
+	return updated
 }
 
-func getUserSearchTerm(text string) string {
-	nearbyIndex := strings.LastIndex(text, userLocationDelimiterNearby)
-	nearMeIndex := strings.LastIndex(text, userLocationDelimiterNearMe)
-	inIndex := strings.LastIndex(text, searchLocationDelimiter)
-	var term string
-
-	if nearbyIndex > -1 {
-		term = text[0:nearbyIndex]
-	} else if nearMeIndex > -1 {
-		term = text[0:nearMeIndex]
-	} else if inIndex > -1 {
-		term = text[0:inIndex]
+// recentLocation returns the location the user shared, if they shared one
+// within locationMaxAge.
+func (svc botService) recentLocation(key SessionKey) (model.Coordinates, bool) {
+	session := svc.getUserSession(key)
+	if session.LocationUpdated.IsZero() || time.Since(session.LocationUpdated) > svc.locationMaxAge {
+		return model.Coordinates{}, false
 	}
 
-	return term
+	return session.Location, true
+}
+
+func (svc botService) updateUserLastQuery(key SessionKey, query model.Query) {
+	svc.updateUserSession(key, func(session *model.UserLocationInfo) {
+		session.LastCommand = query.Command
+		session.LastQuery = query
+	})
 }
 
-func getUserSepcifiedSearchLocation(text string) string {
-	inIndex := strings.LastIndex(text, searchLocationDelimiter)
-	var location string
+func (svc botService) updateUserLastSearch(key SessionKey, term string, location string, coordinates model.Coordinates, options model.SearchOptions) model.UserLocationInfo {
+	var updated model.UserLocationInfo
 
-	if inIndex > -1 {
-		location = text[inIndex+len(searchLocationDelimiter) : len(text)]
-	}
+	svc.updateUserSession(key, func(session *model.UserLocationInfo) {
+		session.LastSearchTerm = term
+		session.LastSearchLocation = location
+		session.LastSearchCoordinates = coordinates
+		session.LastSearchOptions = options
+		session.LastSearchID++
+		updated = *session
+	})
 
-	return location
+	return updated
 }
 
-func (svc botService) createSearchResponse(response *model.Message, result model.SearchResponse) {
+func (svc botService) createSearchResponse(response *model.Message, session model.UserLocationInfo, result model.PlaceSearchResult, offset int) {
 	response.ParseMode = "Markdown"
-	response.ReplyMarkup.Keyboard = [][]model.KeyboardButton{}
+	response.Text, response.ReplyMarkup = formatSearchResults(session, result, offset)
+}
 
-	showCount := 10
-	if result.Total < 10 {
-		showCount = result.Total
+// formatSearchResults lists one page of the session's last search, numbered
+// from offset, with inline buttons to show a result's details or move between
+// pages. The buttons carry the search's ID so they stop working once the user
+// searches again.
+func formatSearchResults(session model.UserLocationInfo, result model.PlaceSearchResult, offset int) (string, *model.ReplyMarkup) {
+	term := session.LastSearchTerm
+
+	if len(result.Places) == 0 {
+		return fmt.Sprintf(NoResultsResponseFormat, markdownEscaper.Replace(term)), nil
 	}
 
 	responseString := fmt.Sprintf(
-		"Got %d results searching for %s, here are the top %d!\n\n",
+		"Got %d results searching for %s, here are %d to %d!\n\n",
 		result.Total,
-		svc.UsersCache[response.ChatID].LastSearchTerm,
-		showCount,
+		markdownEscaper.Replace(term),
+		offset+1,
+		offset+len(result.Places),
 	)
 
-	for i, business := range result.Businesses[0:showCount] {
-		businessStr := fmt.Sprintf(
-			"[%d: %s](%s)\n%s, %s\n%s\n\n",
-			i+1,
-			business.Name,
-			business.URL,
-			getStars(business.Rating, business.ReviewCount),
-			business.Price,
-			business.Location.Address1,
+	detailsButtons := []model.InlineKeyboardButton{}
+	saveButtons := []model.InlineKeyboardButton{}
+	for i, place := range result.Places {
+		var address string
+		if len(place.Address) > 0 {
+			address = markdownEscaper.Replace(place.Address[0])
+		}
+
+		placeStr := fmt.Sprintf(
+			"%s\n%s\n%s\n\n",
+			formatPlaceLink(offset+i+1, place),
+			formatRatingAndPrice(place),
+			address,
 		)
 
-		responseString += businessStr
+		responseString += placeStr
+
+		detailsButtons = append(detailsButtons, model.InlineKeyboardButton{
+			Text:         fmt.Sprintf(DetailsButtonTextFormat, offset+i+1),
+			CallbackData: formatCallbackData(detailsCallbackAction, session.LastSearchID, offset, i),
+		})
+
+		saveButtons = append(saveButtons, model.InlineKeyboardButton{
+			Text:         fmt.Sprintf(SaveButtonTextFormat, offset+i+1),
+			CallbackData: formatCallbackData(saveCallbackAction, session.LastSearchID, offset, i),
+		})
+	}
+
+	markup := &model.ReplyMarkup{
+		InlineKeyboard: [][]model.InlineKeyboardButton{detailsButtons, saveButtons},
 	}
 
-	response.Text = responseString
+	navigationButtons := []model.InlineKeyboardButton{}
+	if offset > 0 {
+		navigationButtons = append(navigationButtons, model.InlineKeyboardButton{
+			Text:         PrevPageButtonText,
+			CallbackData: formatCallbackData(pageCallbackAction, session.LastSearchID, previousPageOffset(offset)),
+		})
+	}
+
+	if nextOffset := offset + resultsPageSize; nextOffset < result.Total {
+		navigationButtons = append(navigationButtons, model.InlineKeyboardButton{
+			Text:         NextPageButtonText,
+			CallbackData: formatCallbackData(pageCallbackAction, session.LastSearchID, nextOffset),
+		})
+	}
+
+	if len(navigationButtons) > 0 {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, navigationButtons)
+	}
+
+	return responseString, markup
+}
+
+// formatPlaceLink shows a place's number and name, linked to its page when
+// the provider has one.
+func formatPlaceLink(number int, place model.Place) string {
+	if place.URL == "" {
+		return fmt.Sprintf("*%d: %s*", number, markdownEntityText(place.Name, "*"))
+	}
+
+	return fmt.Sprintf("[%d: %s](%s)", number, markdownEntityText(place.Name, "]"), place.URL)
+}
+
+func formatRatingAndPrice(place model.Place) string {
+	if place.Rating == 0 && place.ReviewCount == 0 {
+		return place.Price
+	}
+
+	return fmt.Sprintf("%s, %s", getStars(place.Rating, place.ReviewCount), place.Price)
 }
 
 func getStars(rating float64, reviewCount int) string {
@@ -262,14 +460,40 @@ func getStars(rating float64, reviewCount int) string {
 	return fmt.Sprintf("%s (%.2f, %d reviews)", stars, rating, reviewCount)
 }
 
+// searchFailureResponse picks the reply for a failed search or lookup, telling
+// the user what went wrong when it's something they can act on.
+func searchFailureResponse(err error) string {
+	switch {
+	case errors.Is(err, ErrYelpInvalidLocation):
+		return InvalidLocationResponse
+	case errors.Is(err, ErrYelpQuotaExhausted):
+		return SearchUnavailableResponse
+	case errors.Is(err, ErrYelpRateLimited):
+		return SearchBusyResponse
+	case errors.Is(err, ErrYelpUnauthorized):
+		return SearchMisconfiguredResponse
+	case errors.Is(err, ErrYelpUnavailable):
+		return UpstreamUnavailableResponse
+	default:
+		return FailedResponse
+	}
+}
+
+func cooldownResponse(wait time.Duration) string {
+	return fmt.Sprintf(CooldownResponseFormat, int(math.Ceil(wait.Seconds())))
+}
+
 func isProvidingLocation(message model.ReceivedMessage) bool {
 	return message.Message.Text == "" &&
 		message.Message.Location.Latitude != 0 &&
 		message.Message.Location.Longitude != 0
 }
 
+// addLocationKeyboardMarkup asks for the user's location with a button. The
+// keyboard is selective so in groups only the user the message replies to
+// sees it.
 func addLocationKeyboardMarkup(message *model.Message) {
-	message.ReplyMarkup = model.ReplyMarkup{
+	message.ReplyMarkup = &model.ReplyMarkup{
 		Keyboard: [][]model.KeyboardButton{
 			[]model.KeyboardButton{
 				model.KeyboardButton{
@@ -279,6 +503,7 @@ func addLocationKeyboardMarkup(message *model.Message) {
 			},
 		},
 		ResizeKeyboard: true,
+		Selective:      true,
 	}
 }
 
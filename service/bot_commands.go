@@ -0,0 +1,191 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+func (svc *botService) registerCommands() {
+	svc.registry.Register(NewCommand(
+		HelpCommand,
+		[]string{StartCommand},
+		"Show what I can do",
+		nil,
+		svc.handleHelp,
+	))
+	svc.registry.Register(NewCommand(
+		SearchCommand,
+		nil,
+		"Search for businesses, \"/search <cuisine/business> in <location>\" or \"nearby\", optionally narrowed with \"cheap\", \"$$\", \"open now\", \"within 1km\", \"sorted by rating\" or \"hot and new\"",
+		searchNeedsLocation,
+		svc.handleSearch,
+	))
+	svc.registry.Register(NewCommand(
+		DetailsCommand,
+		nil,
+		"\"/details <number>\" shows hours, photos and the address of a result from your last search",
+		nil,
+		svc.handleDetails,
+	))
+	svc.registry.Register(NewCommand(
+		ReviewsCommand,
+		nil,
+		"\"/reviews <number>\" shows what people said about a result from your last search",
+		nil,
+		svc.handleReviews,
+	))
+	svc.registry.Register(NewCommand(
+		RandomCommand,
+		nil,
+		"Suggest a random cuisine near you",
+		alwaysNeedsLocation,
+		svc.handleRandom,
+	))
+	svc.registry.Register(NewCommand(
+		ForgetLocationCommand,
+		nil,
+		"Forget the location you last shared",
+		nil,
+		svc.handleForgetLocation,
+	))
+	svc.registry.Register(NewCommand(
+		SetPlaceCommand,
+		nil,
+		"\"/setplace <name> [address]\" saves your location, or an address, to search around later with \"in @<name>\"",
+		setPlaceNeedsLocation,
+		svc.handleSetPlace,
+	))
+	svc.registry.Register(NewCommand(
+		PlacesCommand,
+		nil,
+		"List the places you saved with /setplace",
+		nil,
+		svc.handlePlaces,
+	))
+	svc.registry.Register(NewCommand(
+		FavoritesCommand,
+		nil,
+		"List the places you saved from search results, with their latest rating and whether they're open",
+		nil,
+		svc.handleFavorites,
+	))
+	svc.registry.Register(NewCommand(
+		UnfavoriteCommand,
+		nil,
+		"\"/unfavorite <number>\" removes a place from your favorites",
+		nil,
+		svc.handleUnfavorite,
+	))
+	svc.registry.Register(NewCommand(
+		LunchPollCommand,
+		nil,
+		"\"/lunchpoll <cuisine/business> in <location>\" or \"nearby\" posts a poll of the top places and announces the winner",
+		lunchPollNeedsLocation,
+		svc.handleLunchPoll,
+	))
+}
+
+func searchNeedsLocation(query model.Query) bool {
+	return query.Nearby
+}
+
+func alwaysNeedsLocation(query model.Query) bool {
+	return true
+}
+
+func (svc botService) handleHelp(request CommandRequest, response *model.Message) {
+	response.Text = svc.Greeting()
+}
+
+func (svc botService) handleSearch(request CommandRequest, response *model.Message) {
+	query := request.Query
+
+	location, coordinates, failure := svc.searchLocation(request)
+	if failure != "" {
+		response.Text = failure
+		return
+	}
+
+	log.Printf("[handleSearch] User search term: %s, user search location: %s", query.Term, query.Location)
+	svc.search(request.Session, response, query.Term, location, coordinates, query.Options)
+}
+
+func (svc botService) handleRandom(request CommandRequest, response *model.Message) {
+	svc.search(request.Session, response, getRandomCuisine(), "", *request.Location, model.SearchOptions{})
+}
+
+func (svc botService) handleForgetLocation(request CommandRequest, response *model.Message) {
+	svc.updateUserSession(request.Session, func(session *model.UserLocationInfo) {
+		session.Location = model.Coordinates{}
+		session.LocationUpdated = time.Time{}
+
+		// A search around the location remembers it too, for paging. The
+		// search ID is kept so its buttons don't match a later search.
+		if session.LastSearchLocation == "" {
+			session.LastSearchTerm = ""
+			session.LastSearchCoordinates = model.Coordinates{}
+			session.LastSearchOptions = model.SearchOptions{}
+		}
+	})
+
+	response.Text = LocationForgottenResponse
+}
+
+func (svc botService) handleDetails(request CommandRequest, response *model.Message) {
+	place, number, failure := svc.resultFromCommand(request.Session, request.Query.Arguments, DetailsUsageResponse)
+	if failure != "" {
+		response.Text = failure
+		return
+	}
+
+	details, err := svc.Places.GetPlace(place.ID)
+	if err != nil {
+		log.Printf("[handleDetails] %s", err.Error())
+		response.Text = searchFailureResponse(err)
+		return
+	}
+
+	response.ParseMode = "Markdown"
+	response.Text = formatPlaceDetails(number, details)
+}
+
+func (svc botService) handleReviews(request CommandRequest, response *model.Message) {
+	place, number, failure := svc.resultFromCommand(request.Session, request.Query.Arguments, ReviewsUsageResponse)
+	if failure != "" {
+		response.Text = failure
+		return
+	}
+
+	reviews, err := svc.Places.GetReviews(place.ID)
+	if err == ErrNotSupported {
+		response.Text = ReviewsUnavailableResponse
+		return
+	} else if err != nil {
+		log.Printf("[handleReviews] %s", err.Error())
+		response.Text = searchFailureResponse(err)
+		return
+	}
+
+	response.ParseMode = "Markdown"
+	response.DisableWebPagePreview = true
+	response.Text = formatReviews(number, place, reviews)
+}
+
+// search runs a search, either in location or around coordinates, remembers
+// it in the user's session so later pages can be fetched, and fills in
+// response with the first page.
+func (svc botService) search(sessionKey SessionKey, response *model.Message, term string, location string, coordinates model.Coordinates, options model.SearchOptions) {
+	session := svc.updateUserLastSearch(sessionKey, term, location, coordinates, options)
+
+	searchResults, err := svc.searchLastPage(session, 0)
+	if err != nil {
+		log.Printf("[search] %s", err.Error())
+
+		response.Text = searchFailureResponse(err)
+		return
+	}
+
+	svc.createSearchResponse(response, session, searchResults, 0)
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+const testGroupChatID = -100
+
+// fakePlaceProvider answers every search with the same five places.
+type fakePlaceProvider struct{}
+
+func (provider fakePlaceProvider) Name() string {
+	return "fake"
+}
+
+func (provider fakePlaceProvider) SearchByLocation(term string, location string, options model.SearchOptions) (model.PlaceSearchResult, error) {
+	return fakeSearchResult(term), nil
+}
+
+func (provider fakePlaceProvider) SearchByCoordinates(term string, latitude float64, longitude float64, options model.SearchOptions) (model.PlaceSearchResult, error) {
+	return fakeSearchResult(term), nil
+}
+
+func (provider fakePlaceProvider) GetPlace(id string) (model.PlaceDetails, error) {
+	return model.PlaceDetails{Place: model.Place{ID: id, Name: "Place " + id}}, nil
+}
+
+func (provider fakePlaceProvider) GetReviews(id string) ([]model.PlaceReview, error) {
+	return nil, ErrNotSupported
+}
+
+func fakeSearchResult(term string) model.PlaceSearchResult {
+	result := model.PlaceSearchResult{Total: 5}
+	for i := 0; i < 5; i++ {
+		result.Places = append(result.Places, model.Place{
+			ID:   fmt.Sprintf("place%d", i),
+			Name: fmt.Sprintf("%s %d", term, i),
+		})
+	}
+
+	return result
+}
+
+// testUpdates makes updates with increasing IDs from many goroutines.
+type testUpdates struct {
+	lastID int64
+}
+
+func (updates *testUpdates) message(chat model.ChatInfo, userID int64, text string) model.ReceivedMessage {
+	message := model.ReceivedMessage{UpdateID: atomic.AddInt64(&updates.lastID, 1)}
+	message.Message.Chat = chat
+	message.Message.MessageID = message.UpdateID
+	message.Message.From.ID = userID
+	message.Message.Text = text
+
+	return message
+}
+
+func (updates *testUpdates) callback(chat model.ChatInfo, userID int64, searcherID int64, data string) model.ReceivedMessage {
+	message := model.ReceivedMessage{UpdateID: atomic.AddInt64(&updates.lastID, 1)}
+	message.CallbackQuery = &model.CallbackQuery{
+		ID:   fmt.Sprint(message.UpdateID),
+		Data: data,
+	}
+	message.CallbackQuery.From.ID = userID
+	message.CallbackQuery.Message.Chat = chat
+	message.CallbackQuery.Message.ReplyToMessage = &model.MessageInfo{From: model.UserInfo{ID: searcherID}}
+
+	return message
+}
+
+// TestCreateResponseMessageConcurrently sends updates for the same users from
+// their private chats and a shared group at once, so it's worth running with
+// -race. Saved places are changed from the private chats while favorites are
+// saved from the group, both in the user's own session, and none of either
+// may be lost.
+func TestCreateResponseMessageConcurrently(t *testing.T) {
+	const (
+		users           = 4
+		placesPerUser   = 10
+		searchesInGroup = 10
+	)
+
+	sessions := NewMemorySessionStore()
+	bot := NewTelegramBot(model.BotInfo{Username: "FoodHelperBot"}, fakePlaceProvider{}, sessions, nil)
+	updates := &testUpdates{}
+	group := model.ChatInfo{ID: testGroupChatID, Type: supergroupChatType}
+
+	var saved [users + 1]int64
+
+	var wg sync.WaitGroup
+	for userID := int64(1); userID <= users; userID++ {
+		private := model.ChatInfo{ID: userID, Type: "private"}
+
+		wg.Add(3)
+
+		go func(userID int64) {
+			defer wg.Done()
+
+			for i := 0; i < placesPerUser; i++ {
+				bot.CreateResponseMessage(updates.message(private, userID, fmt.Sprintf("/setplace place%d %d Main St", i, i)))
+				bot.CreateResponseMessage(updates.message(private, userID, "/search pizza in Paris"))
+				bot.CreateResponseMessage(updates.message(private, userID, "/details 2"))
+			}
+		}(userID)
+
+		go func(userID int64) {
+			defer wg.Done()
+
+			for i := 0; i < searchesInGroup; i++ {
+				response := bot.CreateResponseMessage(updates.message(group, userID, "/search@FoodHelperBot tacos in Oakland"))
+				if response == nil || response.ReplyMarkup == nil {
+					continue
+				}
+
+				// Save each result of the search from the group
+				for _, button := range response.ReplyMarkup.InlineKeyboard[1] {
+					_, answer := bot.CreateCallbackResponse(updates.callback(group, userID, userID, button.CallbackData))
+					if strings.HasPrefix(answer.Text, "Saved ") {
+						atomic.AddInt64(&saved[userID], 1)
+					}
+				}
+			}
+		}(userID)
+
+		go func(userID int64) {
+			defer wg.Done()
+
+			for i := 0; i < searchesInGroup; i++ {
+				bot.CreateResponseMessage(updates.message(group, userID, "/places"))
+				bot.CreateResponseMessage(updates.message(group, userID, "/favorites"))
+				bot.CreateResponseMessage(updates.message(group, userID, "chatter the bot should ignore"))
+			}
+		}(userID)
+	}
+
+	wg.Wait()
+
+	for userID := int64(1); userID <= users; userID++ {
+		session, err := sessions.Get(userSessionKey(userID))
+		if err != nil {
+			t.Fatalf("failed to get session for user %d: %s", userID, err.Error())
+		}
+
+		if len(session.SavedPlaces) != placesPerUser {
+			t.Errorf("user %d has %d saved places, want %d", userID, len(session.SavedPlaces), placesPerUser)
+		}
+
+		if saved[userID] == 0 {
+			t.Errorf("user %d couldn't save any favorites", userID)
+		}
+
+		if int64(len(session.Favorites)) != saved[userID] {
+			t.Errorf("user %d has %d favorites, but %d were saved", userID, len(session.Favorites), saved[userID])
+		}
+	}
+}
+
+// TestSessionsAreCopied checks that changing a session read from a store
+// doesn't change the stored one, which other goroutines may be reading.
+func TestSessionsAreCopied(t *testing.T) {
+	stores := map[string]func(t *testing.T) SessionStore{
+		MemorySessionStore: func(t *testing.T) SessionStore {
+			return NewMemorySessionStore()
+		},
+		JSONSessionStore: func(t *testing.T) SessionStore {
+			store, err := NewJSONSessionStore(t.TempDir() + "/sessions.json")
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		store := newStore(t)
+
+		info := model.UserLocationInfo{
+			SavedPlaces: map[string]model.SavedPlace{"home": {Address: "1 Main St"}},
+			Favorites:   []model.FavoritePlace{{ID: "a"}, {ID: "b"}},
+		}
+		if err := store.Put("1", info); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		info.SavedPlaces["work"] = model.SavedPlace{Address: "2 Main St"}
+		info.Favorites[0].ID = "changed"
+
+		got, _ := store.Get("1")
+		got.SavedPlaces["gym"] = model.SavedPlace{Address: "3 Main St"}
+		got.Favorites[1].ID = "changed"
+
+		stored, _ := store.Get("1")
+		if len(stored.SavedPlaces) != 1 || stored.Favorites[0].ID != "a" || stored.Favorites[1].ID != "b" {
+			t.Errorf("%s store's session was changed through a copy: %+v", name, stored)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+const (
+	resultsPageSize = 5
+
+	// Inline keyboard callback data is "<action>:<search ID>:<arg>[:<arg>...]",
+	// where the search ID is the user's search the buttons were made for. Page
+	// callbacks carry the offset of the page to show, details and save
+	// callbacks carry the offset of the page the result is on and its position
+	// within the page.
+	pageCallbackAction    = "page"
+	detailsCallbackAction = "details"
+	saveCallbackAction    = "save"
+	callbackDataSeparator = ":"
+
+	NextPageButtonText      = "Next 5 ▶️"
+	PrevPageButtonText      = "◀️ Prev"
+	BackButtonText          = "◀️ Back to results"
+	DetailsButtonTextFormat = "Details %d"
+	SaveButtonTextFormat    = "⭐ Save %d"
+	SaveButtonText          = "⭐ Save"
+
+	ExpiredSearchResponse = "That search has expired, please search again."
+)
+
+// CreateCallbackResponse handles an inline keyboard button press on one of the
+// bot's search results. The returned edit is nil when the original message
+// should be left alone, the answer is always set so Telegram stops showing a
+// loading indicator on the button.
+func (svc botService) CreateCallbackResponse(message model.ReceivedMessage) (*model.EditMessageText, *model.AnswerCallbackQuery) {
+	query := message.CallbackQuery
+	answer := &model.AnswerCallbackQuery{
+		CallbackQueryID: query.ID,
+	}
+
+	var edit *model.EditMessageText
+	svc.updates.Do(query.Message.Chat.ID, message.UpdateID, func() {
+		edit = svc.createCallbackResponse(query, answer)
+	})
+
+	return edit, answer
+}
+
+func (svc botService) createCallbackResponse(query *model.CallbackQuery, answer *model.AnswerCallbackQuery) *model.EditMessageText {
+	chatID := query.Message.Chat.ID
+
+	log.Printf("[createCallbackResponse] Got callback - chat ID: %d, message ID: %d, user ID: %d, data: \"%s\"",
+		chatID,
+		query.Message.MessageID,
+		query.From.ID,
+		query.Data,
+	)
+
+	if ok, wait := svc.throttle.Allow(query.From.ID, chatID); !ok {
+		log.Printf("[createCallbackResponse] Throttled user %d in chat %d for %s", query.From.ID, chatID, wait)
+		answer.Text = cooldownResponse(wait)
+		return nil
+	}
+
+	action, args, err := parseCallbackData(query.Data)
+	if err != nil {
+		log.Printf("[createCallbackResponse] %s", err.Error())
+		answer.Text = DefaultResponse
+		return nil
+	}
+
+	// Buttons on an older search's results would otherwise show, or save,
+	// whatever is in the same place in the last search
+	session := svc.getUserSession(callbackSessionKey(query))
+	if !hasLastSearch(session) || args[0] != session.LastSearchID {
+		answer.Text = ExpiredSearchResponse
+		return nil
+	}
+	args = args[1:]
+
+	edit := &model.EditMessageText{
+		ChatID:    chatID,
+		MessageID: query.Message.MessageID,
+		ParseMode: "Markdown",
+	}
+
+	switch {
+	case action == pageCallbackAction && len(args) == 1:
+		offset := args[0]
+
+		results, err := svc.searchLastPage(session, offset)
+		if err != nil {
+			log.Printf("[createCallbackResponse] %s", err.Error())
+			answer.Text = searchFailureResponse(err)
+			return nil
+		}
+
+		edit.Text, edit.ReplyMarkup = formatSearchResults(session, results, offset)
+	case action == detailsCallbackAction && len(args) == 2:
+		offset, position := args[0], args[1]
+
+		results, err := svc.searchLastPage(session, offset)
+		if err != nil {
+			log.Printf("[createCallbackResponse] %s", err.Error())
+			answer.Text = searchFailureResponse(err)
+			return nil
+		}
+
+		if position >= len(results.Places) {
+			answer.Text = ExpiredSearchResponse
+			return nil
+		}
+
+		details, err := svc.Places.GetPlace(results.Places[position].ID)
+		if err != nil {
+			log.Printf("[createCallbackResponse] %s", err.Error())
+			answer.Text = searchFailureResponse(err)
+			return nil
+		}
+
+		edit.Text = formatPlaceDetails(offset+position+1, details)
+		edit.ReplyMarkup = &model.ReplyMarkup{
+			InlineKeyboard: [][]model.InlineKeyboardButton{
+				[]model.InlineKeyboardButton{
+					model.InlineKeyboardButton{
+						Text:         BackButtonText,
+						CallbackData: formatCallbackData(pageCallbackAction, session.LastSearchID, offset),
+					},
+					model.InlineKeyboardButton{
+						Text:         SaveButtonText,
+						CallbackData: formatCallbackData(saveCallbackAction, session.LastSearchID, offset, position),
+					},
+				},
+			},
+		}
+	case action == saveCallbackAction && len(args) == 2:
+		offset, position := args[0], args[1]
+
+		results, err := svc.searchLastPage(session, offset)
+		if err != nil {
+			log.Printf("[createCallbackResponse] %s", err.Error())
+			answer.Text = searchFailureResponse(err)
+			return nil
+		}
+
+		if position >= len(results.Places) {
+			answer.Text = ExpiredSearchResponse
+			return nil
+		}
+
+		// Saving leaves the message as it is, the answer says what happened
+		answer.Text = svc.addFavorite(query.From.ID, results.Places[position])
+		return nil
+	default:
+		log.Printf("[createCallbackResponse] Unknown callback data \"%s\"", query.Data)
+		answer.Text = DefaultResponse
+		return nil
+	}
+
+	return edit
+}
+
+// searchLastPage reruns the user's last search for the page starting at offset.
+func (svc botService) searchLastPage(session model.UserLocationInfo, offset int) (model.PlaceSearchResult, error) {
+	options := session.LastSearchOptions
+	options.Offset = offset
+	options.Limit = resultsPageSize
+
+	return svc.searchPlaces(session.LastSearchTerm, session.LastSearchLocation, session.LastSearchCoordinates, options)
+}
+
+// searchPlaces searches in location when it's set, otherwise around
+// coordinates.
+func (svc botService) searchPlaces(term string, location string, coordinates model.Coordinates, options model.SearchOptions) (model.PlaceSearchResult, error) {
+	if location != "" {
+		return svc.Places.SearchByLocation(term, location, options)
+	}
+
+	return svc.Places.SearchByCoordinates(term, coordinates.Latitude, coordinates.Longitude, options)
+}
+
+// callbackSessionKey is the key of the session holding the search a button
+// belongs to. Results reply to the command that asked for them, so in a group
+// anyone pressing a button sees the pages of that user's search.
+func callbackSessionKey(query *model.CallbackQuery) SessionKey {
+	userID := query.From.ID
+	if query.Message.ReplyToMessage != nil {
+		userID = query.Message.ReplyToMessage.From.ID
+	}
+
+	return chatSessionKey(query.Message.Chat, userID)
+}
+
+func hasLastSearch(session model.UserLocationInfo) bool {
+	return session.LastSearchLocation != "" ||
+		session.LastSearchCoordinates.Latitude != 0 ||
+		session.LastSearchCoordinates.Longitude != 0
+}
+
+func previousPageOffset(offset int) int {
+	if offset < resultsPageSize {
+		return 0
+	}
+
+	return offset - resultsPageSize
+}
+
+func formatCallbackData(action string, args ...int) string {
+	parts := []string{action}
+	for _, arg := range args {
+		parts = append(parts, strconv.Itoa(arg))
+	}
+
+	return strings.Join(parts, callbackDataSeparator)
+}
+
+// parseCallbackData splits callback data into its action and arguments, the
+// first of which is always the search ID.
+func parseCallbackData(data string) (string, []int, error) {
+	parts := strings.Split(data, callbackDataSeparator)
+	if len(parts) < 2 {
+		return "", nil, fmt.Errorf("missing search ID in callback data \"%s\"", data)
+	}
+
+	args := []int{}
+	for _, part := range parts[1:] {
+		arg, err := strconv.Atoi(part)
+		if err != nil || arg < 0 {
+			return "", nil, fmt.Errorf("bad argument \"%s\" in callback data \"%s\"", part, data)
+		}
+
+		args = append(args, arg)
+	}
+
+	return parts[0], args, nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+// Command is a bot command such as "/search". Names and aliases include the
+// leading "/".
+type Command interface {
+	Name() string
+	Aliases() []string
+	Description() string
+	// NeedsLocation reports whether the query can only be answered once the
+	// user has shared their location. The bot asks for it and calls Handle
+	// again with the location when it arrives.
+	NeedsLocation(query model.Query) bool
+	Handle(request CommandRequest, response *model.Message)
+}
+
+// CommandRequest is what a command is asked to answer. Session is the key of
+// the asking user's session in the chat. Location is only set when the
+// command needed the user's location and they have now shared it.
+type CommandRequest struct {
+	Message  model.ReceivedMessage
+	Query    model.Query
+	Session  SessionKey
+	Location *model.Coordinates
+}
+
+type CommandHandler func(request CommandRequest, response *model.Message)
+
+type command struct {
+	name          string
+	aliases       []string
+	description   string
+	needsLocation func(query model.Query) bool
+	handler       CommandHandler
+}
+
+// NewCommand creates a Command from its parts. A nil needsLocation means the
+// command never needs the user's location.
+func NewCommand(name string, aliases []string, description string, needsLocation func(query model.Query) bool, handler CommandHandler) Command {
+	return command{
+		name:          name,
+		aliases:       aliases,
+		description:   description,
+		needsLocation: needsLocation,
+		handler:       handler,
+	}
+}
+
+func (cmd command) Name() string {
+	return cmd.name
+}
+
+func (cmd command) Aliases() []string {
+	return cmd.aliases
+}
+
+func (cmd command) Description() string {
+	return cmd.description
+}
+
+func (cmd command) NeedsLocation(query model.Query) bool {
+	return cmd.needsLocation != nil && cmd.needsLocation(query)
+}
+
+func (cmd command) Handle(request CommandRequest, response *model.Message) {
+	cmd.handler(request, response)
+}
+
+// CommandRegistry looks up commands by name or alias and keeps them in the
+// order they were registered, which is the order they are listed in for
+// users.
+type CommandRegistry struct {
+	commands []Command
+	byName   map[string]Command
+}
+
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{
+		byName: make(map[string]Command),
+	}
+}
+
+// Register adds cmd to the registry. It panics if the name or an alias is
+// already taken, since that can only be a mistake in how the bot is set up.
+func (registry *CommandRegistry) Register(cmd Command) {
+	names := append([]string{cmd.Name()}, cmd.Aliases()...)
+	for _, name := range names {
+		if _, ok := registry.byName[name]; ok {
+			panic(fmt.Sprintf("command %s registered twice", name))
+		}
+	}
+
+	for _, name := range names {
+		registry.byName[name] = cmd
+	}
+
+	registry.commands = append(registry.commands, cmd)
+}
+
+func (registry *CommandRegistry) Lookup(name string) (Command, bool) {
+	cmd, ok := registry.byName[name]
+	return cmd, ok
+}
+
+func (registry *CommandRegistry) Commands() []Command {
+	return registry.commands
+}
+
+// Help lists each command with its aliases and description, one per line.
+func (registry *CommandRegistry) Help() string {
+	var help string
+	for _, cmd := range registry.commands {
+		names := append([]string{cmd.Name()}, cmd.Aliases()...)
+		help += fmt.Sprintf("%s - %s\n", strings.Join(names, ", "), cmd.Description())
+	}
+
+	return help
+}
+
+// BotCommands describes the registered commands the way Telegram's
+// setMyCommands expects, without the leading "/". Aliases aren't published.
+func (registry *CommandRegistry) BotCommands() []model.BotCommand {
+	botCommands := []model.BotCommand{}
+	for _, cmd := range registry.commands {
+		botCommands = append(botCommands, model.BotCommand{
+			Command:     strings.TrimPrefix(cmd.Name(), commandPrefix),
+			Description: cmd.Description(),
+		})
+	}
+
+	return botCommands
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+const (
+	reviewsToShow    = 3
+	reviewDateLayout = "Jan 2, 2006"
+)
+
+// errNoSuchResult means a result number is past the end of the last search.
+var errNoSuchResult = errors.New("no such result in last search")
+
+var weekdays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// markdownEscaper escapes the characters Telegram's legacy Markdown treats as
+// formatting so free text such as an address can't break the message.
+var markdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+
+// markdownEntityText readies free text such as a place's name to go inside a
+// bold, italic or link entity. Telegram doesn't unescape anything there and
+// ends the entity at the first closing character, so that's dropped instead.
+func markdownEntityText(text string, closing string) string {
+	return strings.ReplaceAll(text, closing, "")
+}
+
+// resultFromCommand looks up the result from the user's last search named by a
+// command argument such as the "3" in "/details 3". When the argument or the
+// last search can't be used it returns a message to send back instead.
+func (svc botService) resultFromCommand(sessionKey SessionKey, argument string, usage string) (model.Place, int, string) {
+	number, err := strconv.Atoi(strings.TrimSpace(argument))
+	if err != nil || number < 1 {
+		return model.Place{}, 0, usage
+	}
+
+	session := svc.getUserSession(sessionKey)
+	if !hasLastSearch(session) {
+		return model.Place{}, 0, ExpiredSearchResponse
+	}
+
+	place, err := svc.placeFromLastSearch(session, number)
+	if err != nil {
+		log.Printf("[resultFromCommand] %s", err.Error())
+		if errors.Is(err, errNoSuchResult) {
+			return model.Place{}, 0, usage
+		}
+
+		return model.Place{}, 0, searchFailureResponse(err)
+	}
+
+	return place, number, ""
+}
+
+// placeFromLastSearch finds the place shown as number in the user's last
+// search results. Results are numbered from 1 across pages.
+func (svc botService) placeFromLastSearch(session model.UserLocationInfo, number int) (model.Place, error) {
+	index := number - 1
+	offset := index - index%resultsPageSize
+	position := index % resultsPageSize
+
+	results, err := svc.searchLastPage(session, offset)
+	if err != nil {
+		return model.Place{}, err
+	}
+
+	if position >= len(results.Places) {
+		return model.Place{}, fmt.Errorf("%w: %d for %s", errNoSuchResult, number, session.LastSearchTerm)
+	}
+
+	return results.Places[position], nil
+}
+
+func formatPlaceDetails(number int, details model.PlaceDetails) string {
+	detailsString := fmt.Sprintf(
+		"%s\n%s\n%s\n\n%s\n%s\n",
+		formatPlaceLink(number, details.Place),
+		formatRatingAndPrice(details.Place),
+		markdownEscaper.Replace(strings.Join(details.Categories, ", ")),
+		markdownEscaper.Replace(strings.Join(details.Address, "\n")),
+		markdownEscaper.Replace(details.Phone),
+	)
+
+	if len(details.Hours) > 0 {
+		if details.IsOpenNow {
+			detailsString += "\n*Open now*\n"
+		} else {
+			detailsString += "\n*Closed now*\n"
+		}
+
+		detailsString += formatOpenTimes(details.Hours)
+	}
+
+	if len(details.Photos) > 0 {
+		detailsString += "\n"
+		for i, photo := range details.Photos {
+			detailsString += fmt.Sprintf("[Photo %d](%s) ", i+1, photo)
+		}
+		detailsString += "\n"
+	}
+
+	return detailsString
+}
+
+// formatOpenTimes lists each day's opening periods on its own line, for
+// example "Mon 11:00-14:00, 17:00-22:00".
+func formatOpenTimes(openTimes []model.OpenTime) string {
+	periodsByDay := make([][]string, len(weekdays))
+	for _, open := range openTimes {
+		if open.Day < 0 || open.Day >= len(weekdays) {
+			continue
+		}
+
+		periodsByDay[open.Day] = append(periodsByDay[open.Day], formatClockTime(open.Start)+"-"+formatClockTime(open.End))
+	}
+
+	var hoursString string
+	for day, periods := range periodsByDay {
+		if len(periods) == 0 {
+			hoursString += fmt.Sprintf("%s closed\n", weekdays[day])
+		} else {
+			hoursString += fmt.Sprintf("%s %s\n", weekdays[day], strings.Join(periods, ", "))
+		}
+	}
+
+	return hoursString
+}
+
+func formatClockTime(hhmm string) string {
+	if len(hhmm) != 4 {
+		return hhmm
+	}
+
+	return hhmm[0:2] + ":" + hhmm[2:4]
+}
+
+func formatReviews(number int, place model.Place, reviews []model.PlaceReview) string {
+	if len(reviews) == 0 {
+		return fmt.Sprintf("%s doesn't have any reviews yet.", formatPlaceLink(number, place))
+	}
+
+	reviewsString := fmt.Sprintf("%s\n%s\n\n", formatPlaceLink(number, place), formatRatingAndPrice(place))
+
+	showCount := reviewsToShow
+	if len(reviews) < showCount {
+		showCount = len(reviews)
+	}
+
+	for _, review := range reviews[0:showCount] {
+		reviewsString += fmt.Sprintf(
+			"%s by %s on %s\n_%s_ [Read more](%s)\n\n",
+			strings.Repeat("⭐️", review.Rating),
+			markdownEscaper.Replace(review.Author),
+			review.Created.Format(reviewDateLayout),
+			markdownEntityText(review.Text, "_"),
+			review.URL,
+		)
+	}
+
+	return reviewsString
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+func TestFormatPlaceLink(t *testing.T) {
+	tests := []struct {
+		place    model.Place
+		expected string
+	}{
+		{
+			place:    model.Place{Name: "Joe's Diner"},
+			expected: "*1: Joe's Diner*",
+		},
+		{
+			place:    model.Place{Name: "Joe's Diner", URL: "https://example.com/joes"},
+			expected: "[1: Joe's Diner](https://example.com/joes)",
+		},
+		{
+			place:    model.Place{Name: "Pho_Ha *24h* [Old Town]"},
+			expected: "*1: Pho_Ha 24h [Old Town]*",
+		},
+		{
+			place:    model.Place{Name: "Pho_Ha *24h* [Old Town]", URL: "https://example.com/pho"},
+			expected: "[1: Pho_Ha *24h* [Old Town](https://example.com/pho)",
+		},
+	}
+
+	for _, test := range tests {
+		if link := formatPlaceLink(1, test.place); link != test.expected {
+			t.Errorf("formatPlaceLink(%q) = %q, want %q", test.place.Name, link, test.expected)
+		}
+	}
+}
+
+func TestFormatPlaceDetailsEscapesFreeText(t *testing.T) {
+	details := model.PlaceDetails{
+		Place: model.Place{
+			Name:       "Taco_Stand",
+			Phone:      "+1 555_0100",
+			Categories: []string{"food_truck", "tacos*"},
+			Address:    []string{"1 Main_St", "[Rear]"},
+		},
+	}
+
+	expected := "*1: Taco_Stand*\n\nfood\\_truck, tacos\\*\n\n1 Main\\_St\n\\[Rear]\n+1 555\\_0100\n"
+	if text := formatPlaceDetails(1, details); text != expected {
+		t.Errorf("formatPlaceDetails\n got: %q\nwant: %q", text, expected)
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+// Every favorite is looked up again when listing them, so keep the list short.
+const maxFavorites = 20
+
+// addFavorite saves place to the user's favorites and returns what to tell
+// them.
+func (svc botService) addFavorite(userID int64, place model.Place) string {
+	var response string
+
+	svc.updateUserSession(userSessionKey(userID), func(session *model.UserLocationInfo) {
+		for _, favorite := range session.Favorites {
+			if favorite.ID == place.ID {
+				response = fmt.Sprintf(FavoriteExistsResponseFormat, place.Name)
+				return
+			}
+		}
+
+		if len(session.Favorites) >= maxFavorites {
+			response = fmt.Sprintf(TooManyFavoritesResponseFormat, maxFavorites)
+			return
+		}
+
+		session.Favorites = append(session.Favorites, model.FavoritePlace{
+			ID:   place.ID,
+			Name: place.Name,
+			URL:  place.URL,
+		})
+		response = fmt.Sprintf(FavoriteSavedResponseFormat, place.Name)
+	})
+
+	log.Printf("[addFavorite] User %d: %s", userID, response)
+
+	return response
+}
+
+// handleFavorites lists the user's favorites, looking each one up again so
+// the rating and whether it's open are current.
+func (svc botService) handleFavorites(request CommandRequest, response *model.Message) {
+	favorites := svc.getUserSession(userSessionKey(request.Message.Message.From.ID)).Favorites
+	if len(favorites) == 0 {
+		response.Text = NoFavoritesResponse
+		return
+	}
+
+	var favoritesString string
+	for i, favorite := range favorites {
+		details, err := svc.Places.GetPlace(favorite.ID)
+		if err != nil {
+			log.Printf("[handleFavorites] %s", err.Error())
+
+			place := model.Place{Name: favorite.Name, URL: favorite.URL}
+			favoritesString += fmt.Sprintf("%s\nCouldn't get the latest details\n\n", formatPlaceLink(i+1, place))
+			continue
+		}
+
+		favoritesString += fmt.Sprintf("%s\n%s\n", formatPlaceLink(i+1, details.Place), formatRatingAndPrice(details.Place))
+		if len(details.Hours) > 0 {
+			if details.IsOpenNow {
+				favoritesString += "*Open now*\n"
+			} else {
+				favoritesString += "*Closed now*\n"
+			}
+		}
+		favoritesString += "\n"
+	}
+
+	response.ParseMode = "Markdown"
+	response.DisableWebPagePreview = true
+	response.Text = favoritesString
+}
+
+func (svc botService) handleUnfavorite(request CommandRequest, response *model.Message) {
+	number, err := strconv.Atoi(strings.TrimSpace(request.Query.Arguments))
+	if err != nil || number < 1 {
+		response.Text = UnfavoriteUsageResponse
+		return
+	}
+
+	var removed *model.FavoritePlace
+	svc.updateUserSession(userSessionKey(request.Message.Message.From.ID), func(session *model.UserLocationInfo) {
+		if number > len(session.Favorites) {
+			return
+		}
+
+		favorite := session.Favorites[number-1]
+		removed = &favorite
+
+		// Build a new list rather than shifting the old one along, which
+		// would change the array the session was read from
+		favorites := make([]model.FavoritePlace, 0, len(session.Favorites)-1)
+		favorites = append(favorites, session.Favorites[:number-1]...)
+		session.Favorites = append(favorites, session.Favorites[number:]...)
+	})
+
+	if removed == nil {
+		response.Text = UnfavoriteUsageResponse
+		return
+	}
+
+	response.Text = fmt.Sprintf(UnfavoritedResponseFormat, removed.Name)
+}
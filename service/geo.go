@@ -0,0 +1,67 @@
+package service
+
+import (
+	"math"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+const earthRadiusMeters = 6371000
+
+// distanceMeters is the great circle distance between two coordinates.
+func distanceMeters(from model.Coordinates, to model.Coordinates) float64 {
+	fromLatitude := from.Latitude * math.Pi / 180
+	toLatitude := to.Latitude * math.Pi / 180
+	deltaLatitude := (to.Latitude - from.Latitude) * math.Pi / 180
+	deltaLongitude := (to.Longitude - from.Longitude) * math.Pi / 180
+
+	a := math.Sin(deltaLatitude/2)*math.Sin(deltaLatitude/2) +
+		math.Cos(fromLatitude)*math.Cos(toLatitude)*math.Sin(deltaLongitude/2)*math.Sin(deltaLongitude/2)
+
+	return earthRadiusMeters * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// geohash encodes coordinates as a geohash of precision characters. Nearby
+// coordinates share a prefix, at 7 characters each hash covers roughly a
+// 150m square.
+func geohash(latitude float64, longitude float64, precision int) string {
+	latitudeRange := [2]float64{-90, 90}
+	longitudeRange := [2]float64{-180, 180}
+
+	hash := make([]byte, 0, precision)
+	bits, bit := 0, 0
+	evenBit := true
+
+	for len(hash) < precision {
+		if evenBit {
+			mid := (longitudeRange[0] + longitudeRange[1]) / 2
+			if longitude >= mid {
+				bits = bits<<1 | 1
+				longitudeRange[0] = mid
+			} else {
+				bits = bits << 1
+				longitudeRange[1] = mid
+			}
+		} else {
+			mid := (latitudeRange[0] + latitudeRange[1]) / 2
+			if latitude >= mid {
+				bits = bits<<1 | 1
+				latitudeRange[0] = mid
+			} else {
+				bits = bits << 1
+				latitudeRange[1] = mid
+			}
+		}
+
+		evenBit = !evenBit
+
+		if bit++; bit == 5 {
+			hash = append(hash, geohashAlphabet[bits])
+			bits, bit = 0, 0
+		}
+	}
+
+	return string(hash)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache is a fixed size cache that evicts the least recently used entry
+// when full and treats entries older than its TTL as missing.
+type lruCache struct {
+	lock    sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+
+	onEvict func()
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+func (cache *lruCache) Get(key string) (interface{}, bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*lruEntry)
+	if !entry.expires.IsZero() && cache.now().After(entry.expires) {
+		cache.remove(element)
+		return nil, false
+	}
+
+	cache.order.MoveToFront(element)
+
+	return entry.value, true
+}
+
+func (cache *lruCache) Add(key string, value interface{}) {
+	cache.AddWithTTL(key, value, cache.ttl)
+}
+
+// AddWithTTL adds an entry that expires after ttl rather than the cache's TTL.
+// A ttl of 0 keeps it until it's evicted.
+func (cache *lruCache) AddWithTTL(key string, value interface{}, ttl time.Duration) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = cache.now().Add(ttl)
+	}
+
+	if element, ok := cache.entries[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = expires
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	cache.entries[key] = cache.order.PushFront(&lruEntry{
+		key:     key,
+		value:   value,
+		expires: expires,
+	})
+
+	for cache.order.Len() > cache.size {
+		cache.remove(cache.order.Back())
+
+		if cache.onEvict != nil {
+			cache.onEvict()
+		}
+	}
+}
+
+func (cache *lruCache) Len() int {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	return cache.order.Len()
+}
+
+// remove drops element from the cache. Callers must hold the lock.
+func (cache *lruCache) remove(element *list.Element) {
+	cache.order.Remove(element)
+	delete(cache.entries, element.Value.(*lruEntry).key)
+}
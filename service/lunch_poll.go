@@ -0,0 +1,219 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+const (
+	defaultLunchPollOptions  = 5
+	defaultLunchPollDuration = 10 * time.Minute
+	defaultLunchPollTerm     = "lunch"
+
+	// Telegram's limits on polls
+	minPollOptions        = 2
+	maxPollOptions        = 10
+	maxPollQuestionLength = 300
+	maxPollOptionLength   = 100
+)
+
+// Messenger sends messages that aren't a reply to an update, such as the
+// result of a lunch poll when its timer runs out.
+type Messenger interface {
+	SendMessage(message model.Message) error
+	SendPoll(poll model.SendPoll) (model.SendMessageResult, error)
+	StopPoll(stop model.StopPoll) (model.Poll, error)
+}
+
+// lunchPoll is a poll the bot posted, with the places its options stand for.
+type lunchPoll struct {
+	chatID    int64
+	messageID int64
+	term      string
+	places    []model.Place
+	timer     *time.Timer
+}
+
+// lunchPolls keeps the polls that are still open, by poll ID. They only live
+// in memory, so polls open when the bot restarts are never announced.
+type lunchPolls struct {
+	lock  sync.Mutex
+	polls map[string]*lunchPoll
+}
+
+func newLunchPolls() *lunchPolls {
+	return &lunchPolls{
+		polls: make(map[string]*lunchPoll),
+	}
+}
+
+func (polls *lunchPolls) add(pollID string, poll *lunchPoll) {
+	polls.lock.Lock()
+	defer polls.lock.Unlock()
+
+	polls.polls[pollID] = poll
+}
+
+// take removes the poll and stops its timer, so only the first of the timer
+// and the poll being closed by hand announces the result.
+func (polls *lunchPolls) take(pollID string) *lunchPoll {
+	polls.lock.Lock()
+	defer polls.lock.Unlock()
+
+	poll, ok := polls.polls[pollID]
+	if !ok {
+		return nil
+	}
+
+	delete(polls.polls, pollID)
+	poll.timer.Stop()
+
+	return poll
+}
+
+func lunchPollNeedsLocation(query model.Query) bool {
+	return query.Nearby
+}
+
+// handleLunchPoll searches like /search, then posts a poll with the top
+// results as options that closes after lunchPollDuration.
+func (svc botService) handleLunchPoll(request CommandRequest, response *model.Message) {
+	query := request.Query
+
+	term := query.Term
+	if term == "" {
+		term = defaultLunchPollTerm
+	}
+
+	location, coordinates, failure := svc.searchLocation(request)
+	if failure != "" {
+		response.Text = failure
+		return
+	}
+
+	options := query.Options
+	options.Limit = svc.lunchPollOptions
+
+	results, err := svc.searchPlaces(term, location, coordinates, options)
+	if err != nil {
+		log.Printf("[handleLunchPoll] %s", err.Error())
+		response.Text = searchFailureResponse(err)
+		return
+	}
+
+	if len(results.Places) < minPollOptions {
+		response.Text = fmt.Sprintf(LunchPollTooFewResultsResponseFormat, term)
+		return
+	}
+
+	pollOptions := []model.InputPollOption{}
+	for _, place := range results.Places {
+		pollOptions = append(pollOptions, model.InputPollOption{Text: truncate(place.Name, maxPollOptionLength)})
+	}
+
+	sent, err := svc.messenger.SendPoll(model.SendPoll{
+		ChatID:           response.ChatID,
+		Question:         truncate(fmt.Sprintf(LunchPollQuestionFormat, term), maxPollQuestionLength),
+		Options:          pollOptions,
+		IsAnonymous:      false,
+		ReplyToMessageID: request.Message.Message.MessageID,
+	})
+	if err != nil || sent.Poll == nil {
+		log.Printf("[handleLunchPoll] Failed to send poll: %v", err)
+		response.Text = LunchPollFailedResponse
+		return
+	}
+
+	pollID := sent.Poll.ID
+	svc.polls.add(pollID, &lunchPoll{
+		chatID:    response.ChatID,
+		messageID: sent.MessageID,
+		term:      term,
+		places:    results.Places,
+		timer: time.AfterFunc(svc.lunchPollDuration, func() {
+			svc.closeLunchPoll(pollID, nil)
+		}),
+	})
+
+	log.Printf("[handleLunchPoll] Started poll %s in chat %d for %s", pollID, response.ChatID, term)
+
+	// The poll says everything, there's nothing to reply with
+	response.Text = ""
+}
+
+// HandlePoll is given the new state of a poll. Polls the bot posted that were
+// closed by hand are announced straight away.
+func (svc botService) HandlePoll(poll model.Poll) {
+	if poll.IsClosed {
+		svc.closeLunchPoll(poll.ID, &poll)
+	}
+}
+
+// closeLunchPoll announces the winner of a lunch poll. Without the final
+// state of the poll it stops the poll first, which returns it.
+func (svc botService) closeLunchPoll(pollID string, final *model.Poll) {
+	poll := svc.polls.take(pollID)
+	if poll == nil {
+		return
+	}
+
+	if final == nil {
+		stopped, err := svc.messenger.StopPoll(model.StopPoll{ChatID: poll.chatID, MessageID: poll.messageID})
+		if err != nil {
+			log.Printf("[closeLunchPoll] Failed to stop poll %s: %s", pollID, err.Error())
+			return
+		}
+
+		final = &stopped
+	}
+
+	announcement := model.NewMessage(poll.chatID, svc.formatLunchPollResult(poll, *final))
+	announcement.ParseMode = "Markdown"
+	announcement.ReplyToMessageID = poll.messageID
+
+	if err := svc.messenger.SendMessage(*announcement); err != nil {
+		log.Printf("[closeLunchPoll] Failed to announce poll %s: %s", pollID, err.Error())
+	}
+}
+
+// formatLunchPollResult names the option with the most votes, the one ranked
+// higher by the search when tied, with its details.
+func (svc botService) formatLunchPollResult(poll *lunchPoll, final model.Poll) string {
+	if final.TotalVoterCount == 0 {
+		return fmt.Sprintf(LunchPollNoVotesResponseFormat, markdownEscaper.Replace(poll.term))
+	}
+
+	winner := 0
+	for i, option := range final.Options {
+		if i < len(poll.places) && option.VoterCount > final.Options[winner].VoterCount {
+			winner = i
+		}
+	}
+
+	place := poll.places[winner]
+	resultString := fmt.Sprintf(LunchPollWinnerResponseFormat, markdownEscaper.Replace(place.Name), final.Options[winner].VoterCount, final.TotalVoterCount)
+
+	details, err := svc.Places.GetPlace(place.ID)
+	if err != nil {
+		log.Printf("[formatLunchPollResult] %s", err.Error())
+		return resultString + fmt.Sprintf("%s\n%s\n", formatPlaceLink(winner+1, place), formatRatingAndPrice(place))
+	}
+
+	return resultString + formatPlaceDetails(winner+1, details)
+}
+
+// truncate shortens text to at most limit characters.
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	runes := []rune(text)
+
+	return string(runes[:limit-1]) + "…"
+}
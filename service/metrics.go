@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a count that only goes up, safe to use from many goroutines.
+type Counter struct {
+	value uint64
+}
+
+func (counter *Counter) Inc() {
+	atomic.AddUint64(&counter.value, 1)
+}
+
+func (counter *Counter) Value() uint64 {
+	return atomic.LoadUint64(&counter.value)
+}
+
+// Gauge is a value that can go up and down, safe to use from many goroutines.
+type Gauge struct {
+	value int64
+}
+
+func (gauge *Gauge) Set(value int64) {
+	atomic.StoreInt64(&gauge.value, value)
+}
+
+func (gauge *Gauge) Value() int64 {
+	return atomic.LoadInt64(&gauge.value)
+}
+
+var (
+	metricsLock sync.Mutex
+	counters    = make(map[string]*Counter)
+	gauges      = make(map[string]*Gauge)
+)
+
+// NewCounter returns the counter registered as name, creating it if needed,
+// so it's reported by Metrics.
+func NewCounter(name string) *Counter {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+
+	if counter, ok := counters[name]; ok {
+		return counter
+	}
+
+	counter := &Counter{}
+	counters[name] = counter
+
+	return counter
+}
+
+// NewGauge returns the gauge registered as name, creating it if needed, so
+// it's reported by Metrics.
+func NewGauge(name string) *Gauge {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+
+	if gauge, ok := gauges[name]; ok {
+		return gauge
+	}
+
+	gauge := &Gauge{}
+	gauges[name] = gauge
+
+	return gauge
+}
+
+// Metrics lists every registered counter and gauge as "name value" lines,
+// sorted by name, which is the Prometheus text format for untyped metrics.
+func Metrics() string {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+
+	values := make(map[string]string)
+	for name, counter := range counters {
+		values[name] = strconv.FormatUint(counter.Value(), 10)
+	}
+
+	for name, gauge := range gauges {
+		values[name] = strconv.FormatInt(gauge.Value(), 10)
+	}
+
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var metrics string
+	for _, name := range names {
+		metrics += fmt.Sprintf("%s %s\n", name, values[name])
+	}
+
+	return metrics
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultOutboundTimeout  = 10 * time.Second
+	defaultOutboundRetries  = 2
+	defaultBreakerFailures  = 5
+	defaultBreakerOpenFor   = 30 * time.Second
+	outboundRetryBaseDelay  = 250 * time.Millisecond
+	outboundRetryMaxDelay   = 5 * time.Second
+	outboundMaxRetryAfter   = 30 * time.Second
+	outboundErrorBodyMaxLen = 64 * 1024
+)
+
+// ErrCircuitOpen is returned without making a request while an upstream has
+// been failing, so callers fail fast instead of piling up waiting on it.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// outboundClient is the HTTP client for one upstream such as Telegram or
+// Yelp. Every attempt gets a timeout, 429 and 5xx responses are retried with
+// jittered exponential backoff, as are network errors for requests that are
+// safe to send twice, and a circuit breaker stops calling the upstream for a
+// while after it fails repeatedly.
+type outboundClient struct {
+	name    string
+	client  *http.Client
+	timeout time.Duration
+	retries int
+	breaker *circuitBreaker
+
+	// permanent reports whether a 429 or 5xx body says retrying won't help,
+	// such as Yelp's daily quota running out. It may be nil.
+	permanent func(body []byte) bool
+
+	// resendable reports whether req can be sent again after a network error
+	// or timeout, when the upstream may already have acted on it. It may be
+	// nil, in which case every request can.
+	resendable func(req *http.Request) bool
+
+	retryCount *Counter
+}
+
+// newOutboundClient reads its settings from the config under prefix, for
+// example "yelp.timeout" and "yelp.circuit_breaker.failures".
+func newOutboundClient(name string, prefix string) *outboundClient {
+	timeout := viper.GetDuration(prefix + ".timeout")
+	if timeout <= 0 {
+		timeout = defaultOutboundTimeout
+	}
+
+	retries := defaultOutboundRetries
+	if viper.IsSet(prefix + ".retries") {
+		retries = viper.GetInt(prefix + ".retries")
+	}
+
+	failures := viper.GetInt(prefix + ".circuit_breaker.failures")
+	if failures <= 0 {
+		failures = defaultBreakerFailures
+	}
+
+	openFor := viper.GetDuration(prefix + ".circuit_breaker.open_for")
+	if openFor <= 0 {
+		openFor = defaultBreakerOpenFor
+	}
+
+	return &outboundClient{
+		name:       name,
+		client:     &http.Client{},
+		timeout:    timeout,
+		retries:    retries,
+		breaker:    newCircuitBreaker(name, failures, openFor),
+		retryCount: NewCounter(name + "_retries_total"),
+	}
+}
+
+// Do sends req, giving each attempt the client's timeout to complete including
+// reading the body. Requests whose context already has a deadline, such as
+// long polls, run until that deadline instead. Requests with a body must be
+// made with http.NewRequest so the body can be sent again when retrying.
+func (client *outboundClient) Do(req *http.Request) (*http.Response, error) {
+	if !client.breaker.Allow() {
+		return nil, fmt.Errorf("%w for %s", ErrCircuitOpen, client.name)
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := client.attempt(req)
+		if err != nil && errors.Is(req.Context().Err(), context.Canceled) {
+			// The caller gave up, the upstream didn't fail
+			client.breaker.Abandon()
+			return nil, err
+		} else if err != nil {
+			// Running out the caller's own deadline, such as a long poll's,
+			// still counts as a failure, it just can't be retried
+			client.breaker.Failure()
+			if req.Context().Err() != nil || attempt >= client.retries || (client.resendable != nil && !client.resendable(req)) {
+				return nil, err
+			}
+
+			client.wait(attempt, 0, describeAttemptError(req, err))
+			continue
+		}
+
+		// Being rate limited means the upstream is up, so it counts as a
+		// success for the breaker, which also ends a trial call
+		if res.StatusCode < 500 {
+			client.breaker.Success()
+		} else {
+			client.breaker.Failure()
+		}
+
+		if res.StatusCode != http.StatusTooManyRequests && res.StatusCode < 500 {
+			return res, nil
+		}
+
+		body := readErrorBody(res)
+		retryAfter := parseRetryAfter(res.Header, body)
+		if attempt >= client.retries || retryAfter > outboundMaxRetryAfter || (client.permanent != nil && client.permanent(body)) {
+			return res, nil
+		}
+
+		client.wait(attempt, retryAfter, res.Status)
+	}
+}
+
+func (client *outboundClient) attempt(req *http.Request) (*http.Response, error) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if _, ok := req.Context().Deadline(); ok {
+		ctx, cancel = context.WithCancel(req.Context())
+	} else {
+		ctx, cancel = context.WithTimeout(req.Context(), client.timeout)
+	}
+
+	attemptReq := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to copy request body for %s: %s", client.name, err.Error())
+		}
+
+		attemptReq.Body = body
+	}
+
+	res, err := client.client.Do(attemptReq)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	res.Body = cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+
+	return res, nil
+}
+
+// wait sleeps before the next attempt, for retryAfter when the upstream asked
+// for it, otherwise for a jittered exponential backoff.
+func (client *outboundClient) wait(attempt int, retryAfter time.Duration, reason string) {
+	delay := retryAfter
+	if delay <= 0 {
+		backoff := outboundRetryBaseDelay << uint(attempt)
+		if backoff > outboundRetryMaxDelay {
+			backoff = outboundRetryMaxDelay
+		}
+
+		delay = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	}
+
+	log.Printf("[outboundClient] %s attempt %d failed (%s), retrying in %s", client.name, attempt+1, reason, delay)
+	client.retryCount.Inc()
+	time.Sleep(delay)
+}
+
+// describeAttemptError describes a failed attempt by its method instead of its
+// URL, which for Telegram has the bot token in it.
+func describeAttemptError(req *http.Request, err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	return fmt.Sprintf("%s %s: %s", req.Method, path.Base(req.URL.Path), err.Error())
+}
+
+// readErrorBody reads a failed response's body and puts it back, so it can be
+// inspected here and still decoded by the caller.
+func readErrorBody(res *http.Response) []byte {
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, outboundErrorBodyMaxLen))
+	res.Body.Close()
+	if err != nil {
+		body = nil
+	}
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body
+}
+
+// parseRetryAfter reads how long the upstream asked us to wait, either from
+// Telegram's "parameters.retry_after" or a Retry-After header in seconds.
+func parseRetryAfter(header http.Header, body []byte) time.Duration {
+	var telegramError struct {
+		Parameters struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+
+	if err := json.Unmarshal(body, &telegramError); err == nil && telegramError.Parameters.RetryAfter > 0 {
+		return time.Duration(telegramError.Parameters.RetryAfter) * time.Second
+	}
+
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	return 0
+}
+
+// cancelOnClose releases an attempt's timeout once its body has been read.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body cancelOnClose) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+
+	return err
+}
+
+// circuitBreaker opens after failures consecutive failures and rejects calls
+// until openFor has passed. Then it lets a single call through, closing again
+// if it succeeds and staying open for another openFor if it fails.
+type circuitBreaker struct {
+	lock      sync.Mutex
+	failures  int
+	threshold int
+	openFor   time.Duration
+	openUntil time.Time
+	trial     bool
+	now       func() time.Time
+
+	openCount *Counter
+}
+
+func newCircuitBreaker(name string, threshold int, openFor time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		openFor:   openFor,
+		now:       time.Now,
+		openCount: NewCounter(name + "_circuit_opened_total"),
+	}
+}
+
+func (breaker *circuitBreaker) Allow() bool {
+	breaker.lock.Lock()
+	defer breaker.lock.Unlock()
+
+	if breaker.failures < breaker.threshold {
+		return true
+	}
+
+	if breaker.trial || breaker.now().Before(breaker.openUntil) {
+		return false
+	}
+
+	breaker.trial = true
+
+	return true
+}
+
+func (breaker *circuitBreaker) Success() {
+	breaker.lock.Lock()
+	defer breaker.lock.Unlock()
+
+	breaker.failures = 0
+	breaker.trial = false
+}
+
+// Abandon ends a call that neither succeeded nor failed because its caller
+// gave up on it, so a trial call doesn't leave the breaker waiting forever.
+func (breaker *circuitBreaker) Abandon() {
+	breaker.lock.Lock()
+	defer breaker.lock.Unlock()
+
+	breaker.trial = false
+}
+
+func (breaker *circuitBreaker) Failure() {
+	breaker.lock.Lock()
+	defer breaker.lock.Unlock()
+
+	breaker.failures++
+	if breaker.failures >= breaker.threshold && (breaker.trial || breaker.failures == breaker.threshold) {
+		breaker.openUntil = breaker.now().Add(breaker.openFor)
+		breaker.trial = false
+		breaker.openCount.Inc()
+		log.Printf("[circuitBreaker] Opened after %d failures, retrying after %s", breaker.failures, breaker.openUntil)
+	}
+}
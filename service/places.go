@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+const (
+	// Supported place providers, listed in the "places.providers" config value
+	YelpPlaceProvider    = "yelp"
+	GeoJSONPlaceProvider = "geojson"
+)
+
+// ErrNotSupported is returned by providers that can't answer a request, such
+// as a dataset without reviews.
+var ErrNotSupported = errors.New("not supported by this place provider")
+
+// PlaceProvider searches some source of restaurants and other businesses.
+// IDs are only meaningful to the provider that returned them.
+type PlaceProvider interface {
+	Name() string
+	SearchByLocation(term string, location string, options model.SearchOptions) (model.PlaceSearchResult, error)
+	SearchByCoordinates(term string, latitude float64, longitude float64, options model.SearchOptions) (model.PlaceSearchResult, error)
+	GetPlace(id string) (model.PlaceDetails, error)
+	GetReviews(id string) ([]model.PlaceReview, error)
+}
+
+// NewPlaceProvider creates the provider named by providerType. The yelp
+// service is only used by the yelp provider and datasetPath by the geojson
+// provider.
+func NewPlaceProvider(providerType string, yelp YelpService, datasetPath string) (PlaceProvider, error) {
+	switch providerType {
+	case YelpPlaceProvider, "":
+		return NewYelpPlaceProvider(yelp), nil
+	case GeoJSONPlaceProvider:
+		return NewGeoJSONPlaceProvider(datasetPath)
+	default:
+		return nil, fmt.Errorf("unknown place provider %s", providerType)
+	}
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+const (
+	// How many results are asked of each provider. Results are merged before
+	// paging, so pages past this many merged results aren't available. This is
+	// also the most Yelp returns for a single search.
+	federatedSearchWindow = 50
+
+	// Places with the same normalized name closer together than this are
+	// taken to be the same place listed by different providers.
+	duplicatePlaceDistanceMeters = 150
+
+	federatedIDSeparator = ":"
+
+	// How long providers get to answer when "places.search_timeout" isn't set
+	defaultFederatedSearchTimeout = 5 * time.Second
+)
+
+// federatedPlaceProvider searches several providers at once and merges what
+// they find. Place IDs are prefixed with the name of the provider that found
+// them, "yelp:abc123", so lookups go back to the right provider.
+type federatedPlaceProvider struct {
+	providers []PlaceProvider
+	timeout   time.Duration
+}
+
+type providerResult struct {
+	provider PlaceProvider
+	result   model.PlaceSearchResult
+	err      error
+}
+
+// rankedPlace is a merged place along with what's used to rank it, the names
+// of the providers that listed it and its best position in their results.
+type rankedPlace struct {
+	place     model.Place
+	providers []string
+	position  int
+}
+
+// NewFederatedPlaceProvider searches all of providers, giving each up to
+// timeout to answer. Earlier providers win when merging duplicates.
+func NewFederatedPlaceProvider(providers []PlaceProvider, timeout time.Duration) PlaceProvider {
+	if timeout <= 0 {
+		timeout = defaultFederatedSearchTimeout
+	}
+
+	return federatedPlaceProvider{
+		providers: providers,
+		timeout:   timeout,
+	}
+}
+
+func (provider federatedPlaceProvider) Name() string {
+	names := []string{}
+	for _, p := range provider.providers {
+		names = append(names, p.Name())
+	}
+
+	return strings.Join(names, "+")
+}
+
+func (provider federatedPlaceProvider) SearchByLocation(term string, location string, options model.SearchOptions) (model.PlaceSearchResult, error) {
+	return provider.search(options, func(p PlaceProvider, windowOptions model.SearchOptions) (model.PlaceSearchResult, error) {
+		return p.SearchByLocation(term, location, windowOptions)
+	})
+}
+
+func (provider federatedPlaceProvider) SearchByCoordinates(term string, latitude float64, longitude float64, options model.SearchOptions) (model.PlaceSearchResult, error) {
+	return provider.search(options, func(p PlaceProvider, windowOptions model.SearchOptions) (model.PlaceSearchResult, error) {
+		return p.SearchByCoordinates(term, latitude, longitude, windowOptions)
+	})
+}
+
+func (provider federatedPlaceProvider) GetPlace(id string) (model.PlaceDetails, error) {
+	p, providerID, err := provider.providerFor(id)
+	if err != nil {
+		return model.PlaceDetails{}, err
+	}
+
+	details, err := p.GetPlace(providerID)
+	if err != nil {
+		return details, err
+	}
+
+	details.ID = id
+
+	return details, nil
+}
+
+func (provider federatedPlaceProvider) GetReviews(id string) ([]model.PlaceReview, error) {
+	p, providerID, err := provider.providerFor(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetReviews(providerID)
+}
+
+func (provider federatedPlaceProvider) providerFor(id string) (PlaceProvider, string, error) {
+	separatorIndex := strings.Index(id, federatedIDSeparator)
+	if separatorIndex < 0 {
+		return nil, "", fmt.Errorf("place id %s has no provider", id)
+	}
+
+	name := id[0:separatorIndex]
+	for _, p := range provider.providers {
+		if p.Name() == name {
+			return p, id[separatorIndex+1:], nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("no place provider named %s", name)
+}
+
+// search asks every provider for the first federatedSearchWindow results,
+// merges them, and returns the page of the merged results options asks for.
+// Providers that fail or miss the deadline are left out, the search only
+// fails if none of them answer.
+func (provider federatedPlaceProvider) search(options model.SearchOptions, searchProvider func(PlaceProvider, model.SearchOptions) (model.PlaceSearchResult, error)) (model.PlaceSearchResult, error) {
+	windowOptions := options
+	windowOptions.Offset = 0
+	windowOptions.Limit = federatedSearchWindow
+
+	// Buffered so providers that answer after the deadline don't block forever
+	results := make(chan providerResult, len(provider.providers))
+	for _, p := range provider.providers {
+		go func(p PlaceProvider) {
+			result, err := searchProvider(p, windowOptions)
+			results <- providerResult{provider: p, result: result, err: err}
+		}(p)
+	}
+
+	byProvider := make(map[string]model.PlaceSearchResult)
+	var lastErr error
+
+	deadline := time.After(provider.timeout)
+	for waiting := len(provider.providers); waiting > 0; waiting-- {
+		select {
+		case answer := <-results:
+			if answer.err != nil {
+				log.Printf("[federatedPlaceProvider] %s search failed: %s", answer.provider.Name(), answer.err.Error())
+				lastErr = answer.err
+				continue
+			}
+
+			byProvider[answer.provider.Name()] = answer.result
+		case <-deadline:
+			log.Printf("[federatedPlaceProvider] %d providers missed the %s deadline", waiting, provider.timeout)
+			waiting = 0
+		}
+	}
+
+	if len(byProvider) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no place provider answered within %s", provider.timeout)
+		}
+
+		return model.PlaceSearchResult{}, lastErr
+	}
+
+	// Merge in provider order so earlier providers win duplicates
+	merged := []*rankedPlace{}
+	for _, p := range provider.providers {
+		result, ok := byProvider[p.Name()]
+		if !ok {
+			continue
+		}
+
+		for position, place := range result.Places {
+			place.ID = p.Name() + federatedIDSeparator + place.ID
+			merged = mergePlace(merged, p.Name(), place, position)
+		}
+	}
+
+	rankPlaces(merged, options.SortBy)
+
+	places := []model.Place{}
+	for _, ranked := range merged {
+		places = append(places, ranked.place)
+	}
+
+	return pagePlaces(places, options), nil
+}
+
+// mergePlace adds place, found by the provider named providerName, to merged,
+// or folds it into a place already there if another provider listed it too.
+// Places from the same provider are never merged, nearby branches of a chain
+// are different places.
+func mergePlace(merged []*rankedPlace, providerName string, place model.Place, position int) []*rankedPlace {
+	name := normalizePlaceName(place.Name)
+
+	for _, existing := range merged {
+		if normalizePlaceName(existing.place.Name) != name || !samePlaceLocation(existing.place, place) || hasProvider(existing, providerName) {
+			continue
+		}
+
+		existing.providers = append(existing.providers, providerName)
+		if position < existing.position {
+			existing.position = position
+		}
+
+		fillPlace(&existing.place, place)
+
+		return merged
+	}
+
+	return append(merged, &rankedPlace{
+		place:     place,
+		providers: []string{providerName},
+		position:  position,
+	})
+}
+
+func hasProvider(ranked *rankedPlace, providerName string) bool {
+	for _, name := range ranked.providers {
+		if name == providerName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// samePlaceLocation reports whether two places with the same name are close
+// enough to be one place. Places without coordinates are assumed to match.
+func samePlaceLocation(a model.Place, b model.Place) bool {
+	if isZeroCoordinates(a.Coordinates) || isZeroCoordinates(b.Coordinates) {
+		return true
+	}
+
+	return distanceMeters(a.Coordinates, b.Coordinates) <= duplicatePlaceDistanceMeters
+}
+
+func isZeroCoordinates(coordinates model.Coordinates) bool {
+	return coordinates.Latitude == 0 && coordinates.Longitude == 0
+}
+
+// fillPlace copies into place anything it's missing that other knows.
+func fillPlace(place *model.Place, other model.Place) {
+	if place.URL == "" {
+		place.URL = other.URL
+	}
+
+	if place.Rating == 0 && place.ReviewCount == 0 {
+		place.Rating = other.Rating
+		place.ReviewCount = other.ReviewCount
+	}
+
+	if place.Price == "" {
+		place.Price = other.Price
+	}
+
+	if place.Phone == "" {
+		place.Phone = other.Phone
+	}
+
+	if len(place.Categories) == 0 {
+		place.Categories = other.Categories
+	}
+
+	if len(place.Address) == 0 {
+		place.Address = other.Address
+	}
+
+	if place.Distance == 0 {
+		place.Distance = other.Distance
+	}
+
+	if place.ImageURL == "" {
+		place.ImageURL = other.ImageURL
+	}
+}
+
+// normalizePlaceName lower cases name and drops punctuation, spacing and a
+// leading "the", so "The Taco-Shop" and "taco shop" match.
+func normalizePlaceName(name string) string {
+	name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "the ")
+
+	var normalized strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			normalized.WriteRune(r)
+		}
+	}
+
+	return normalized.String()
+}
+
+// rankPlaces orders merged places by sortBy. The default best match ranking
+// puts places more providers agree on first, then each place's best position
+// in any provider's results, then the closest.
+func rankPlaces(places []*rankedPlace, sortBy string) {
+	sort.SliceStable(places, func(i, j int) bool {
+		a, b := places[i], places[j]
+
+		switch sortBy {
+		case "rating":
+			return a.place.Rating > b.place.Rating
+		case "review_count":
+			return a.place.ReviewCount > b.place.ReviewCount
+		case "distance":
+			return a.place.Distance < b.place.Distance
+		}
+
+		if len(a.providers) != len(b.providers) {
+			return len(a.providers) > len(b.providers)
+		}
+
+		if a.position != b.position {
+			return a.position < b.position
+		}
+
+		return a.place.Distance < b.place.Distance
+	})
+}
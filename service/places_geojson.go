@@ -0,0 +1,300 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+// How far from the user to look when a search doesn't give a radius, the
+// same as the most Yelp allows.
+const defaultDatasetRadius = YelpMaxRadius
+
+// geoJSONPlaceProvider searches a local GeoJSON FeatureCollection of points,
+// such as an OpenStreetMap export from Overpass, so the bot can run where Yelp
+// has poor coverage or with no network at all. Properties are read using
+// OpenStreetMap's tag names ("name", "cuisine", "amenity", "addr:street",
+// "phone", "website", ...) plus optional "rating", "review_count", "price" and
+// "image". The open now and attribute search options are ignored since the
+// data rarely has them.
+type geoJSONPlaceProvider struct {
+	places []model.Place
+	byID   map[string]model.Place
+}
+
+type geoJSONFeatureCollection struct {
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	ID         interface{}            `json:"id"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// NewGeoJSONPlaceProvider loads every named point feature from the GeoJSON
+// file at path.
+func NewGeoJSONPlaceProvider(path string) (PlaceProvider, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read places dataset %s: %s", path, err.Error())
+	}
+
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(contents, &collection); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal places dataset %s: %s", path, err.Error())
+	}
+
+	provider := geoJSONPlaceProvider{
+		places: []model.Place{},
+		byID:   make(map[string]model.Place),
+	}
+
+	for i, feature := range collection.Features {
+		if feature.Geometry.Type != "Point" || len(feature.Geometry.Coordinates) < 2 {
+			continue
+		}
+
+		place := geoJSONPlace(feature)
+		if place.Name == "" {
+			continue
+		}
+
+		if place.ID == "" {
+			place.ID = strconv.Itoa(i)
+		}
+
+		provider.places = append(provider.places, place)
+		provider.byID[place.ID] = place
+	}
+
+	return provider, nil
+}
+
+func (provider geoJSONPlaceProvider) Name() string {
+	return GeoJSONPlaceProvider
+}
+
+// SearchByLocation matches places whose address contains location, since
+// there's nothing to geocode it with offline.
+func (provider geoJSONPlaceProvider) SearchByLocation(term string, location string, options model.SearchOptions) (model.PlaceSearchResult, error) {
+	location = strings.ToLower(location)
+
+	matches := []model.Place{}
+	for _, place := range provider.places {
+		if strings.Contains(strings.ToLower(strings.Join(place.Address, ", ")), location) && matchesSearch(place, term, options) {
+			matches = append(matches, place)
+		}
+	}
+
+	return pagePlaces(sortPlaces(matches, options.SortBy), options), nil
+}
+
+func (provider geoJSONPlaceProvider) SearchByCoordinates(term string, latitude float64, longitude float64, options model.SearchOptions) (model.PlaceSearchResult, error) {
+	center := model.Coordinates{Latitude: latitude, Longitude: longitude}
+
+	radius := options.Radius
+	if radius == 0 {
+		radius = defaultDatasetRadius
+	}
+
+	matches := []model.Place{}
+	for _, place := range provider.places {
+		place.Distance = distanceMeters(center, place.Coordinates)
+
+		if place.Distance > float64(radius) {
+			continue
+		}
+
+		if matchesSearch(place, term, options) {
+			matches = append(matches, place)
+		}
+	}
+
+	// Closest first is the best match when searching around the user
+	sortBy := options.SortBy
+	if sortBy == "" || sortBy == "best_match" {
+		sortBy = "distance"
+	}
+
+	return pagePlaces(sortPlaces(matches, sortBy), options), nil
+}
+
+func (provider geoJSONPlaceProvider) GetPlace(id string) (model.PlaceDetails, error) {
+	place, ok := provider.byID[id]
+	if !ok {
+		return model.PlaceDetails{}, fmt.Errorf("no place with id %s in dataset", id)
+	}
+
+	details := model.PlaceDetails{Place: place}
+	if place.ImageURL != "" {
+		details.Photos = []string{place.ImageURL}
+	}
+
+	return details, nil
+}
+
+func (provider geoJSONPlaceProvider) GetReviews(id string) ([]model.PlaceReview, error) {
+	return nil, ErrNotSupported
+}
+
+// matchesSearch reports whether every word of term appears in the place's
+// name or categories and the place passes the price and category options.
+func matchesSearch(place model.Place, term string, options model.SearchOptions) bool {
+	haystack := strings.ToLower(place.Name + " " + strings.Join(place.Categories, " "))
+	for _, word := range strings.Fields(strings.ToLower(term)) {
+		if !strings.Contains(haystack, word) {
+			return false
+		}
+	}
+
+	if len(options.Price) > 0 {
+		found := false
+		for _, price := range options.Price {
+			if len(place.Price) == price {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if len(options.Categories) > 0 {
+		categories := strings.ToLower(strings.Join(place.Categories, " "))
+
+		found := false
+		for _, category := range options.Categories {
+			if strings.Contains(categories, category) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sortPlaces(places []model.Place, sortBy string) []model.Place {
+	switch sortBy {
+	case "rating":
+		sort.SliceStable(places, func(i, j int) bool { return places[i].Rating > places[j].Rating })
+	case "review_count":
+		sort.SliceStable(places, func(i, j int) bool { return places[i].ReviewCount > places[j].ReviewCount })
+	case "distance":
+		sort.SliceStable(places, func(i, j int) bool { return places[i].Distance < places[j].Distance })
+	}
+
+	return places
+}
+
+func pagePlaces(places []model.Place, options model.SearchOptions) model.PlaceSearchResult {
+	result := model.PlaceSearchResult{
+		Total:  len(places),
+		Places: []model.Place{},
+	}
+
+	if options.Offset >= len(places) {
+		return result
+	}
+
+	end := len(places)
+	if options.Limit > 0 && options.Offset+options.Limit < end {
+		end = options.Offset + options.Limit
+	}
+
+	result.Places = places[options.Offset:end]
+
+	return result
+}
+
+func geoJSONPlace(feature geoJSONFeature) model.Place {
+	properties := feature.Properties
+
+	place := model.Place{
+		ID:       geoJSONString(feature.ID),
+		Provider: GeoJSONPlaceProvider,
+		Name:     geoJSONProperty(properties, "name"),
+		URL:      geoJSONProperty(properties, "website", "contact:website", "url"),
+		Phone:    geoJSONProperty(properties, "phone", "contact:phone"),
+		Price:    geoJSONProperty(properties, "price"),
+		ImageURL: geoJSONProperty(properties, "image"),
+		Coordinates: model.Coordinates{
+			Latitude:  feature.Geometry.Coordinates[1],
+			Longitude: feature.Geometry.Coordinates[0],
+		},
+		Categories: []string{},
+		Address:    []string{},
+	}
+
+	if place.ID == "" {
+		place.ID = geoJSONProperty(properties, "@id", "id")
+	}
+
+	if rating, err := strconv.ParseFloat(geoJSONProperty(properties, "rating"), 64); err == nil {
+		place.Rating = rating
+	}
+
+	if reviewCount, err := strconv.Atoi(geoJSONProperty(properties, "review_count")); err == nil {
+		place.ReviewCount = reviewCount
+	}
+
+	// OpenStreetMap separates multiple values with semicolons, "pizza;italian"
+	for _, key := range []string{"cuisine", "amenity", "category"} {
+		for _, category := range strings.Split(geoJSONProperty(properties, key), ";") {
+			if category = strings.TrimSpace(category); category != "" {
+				place.Categories = append(place.Categories, strings.Replace(category, "_", " ", -1))
+			}
+		}
+	}
+
+	street := strings.TrimSpace(geoJSONProperty(properties, "addr:housenumber") + " " + geoJSONProperty(properties, "addr:street"))
+	city := strings.TrimSpace(geoJSONProperty(properties, "addr:city") + " " + geoJSONProperty(properties, "addr:postcode"))
+	for _, line := range []string{geoJSONProperty(properties, "address"), street, city} {
+		if line != "" {
+			place.Address = append(place.Address, line)
+		}
+	}
+
+	return place
+}
+
+// geoJSONProperty returns the first of keys that is set, as a string.
+func geoJSONProperty(properties map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value := geoJSONString(properties[key]); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func geoJSONString(value interface{}) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(typed)
+	default:
+		return ""
+	}
+}
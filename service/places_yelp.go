@@ -0,0 +1,138 @@
+package service
+
+import (
+	"time"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+// Yelp review times look like "2016-08-29 00:41:13"
+const yelpReviewTimeLayout = "2006-01-02 15:04:05"
+
+// yelpPlaceProvider adapts YelpService to PlaceProvider.
+type yelpPlaceProvider struct {
+	yelp YelpService
+}
+
+func NewYelpPlaceProvider(yelp YelpService) PlaceProvider {
+	return yelpPlaceProvider{yelp: yelp}
+}
+
+func (provider yelpPlaceProvider) Name() string {
+	return YelpPlaceProvider
+}
+
+func (provider yelpPlaceProvider) SearchByLocation(term string, location string, options model.SearchOptions) (model.PlaceSearchResult, error) {
+	response, err := provider.yelp.SearchByLocation(term, location, options)
+	if err != nil {
+		return model.PlaceSearchResult{}, err
+	}
+
+	return yelpSearchResult(response), nil
+}
+
+func (provider yelpPlaceProvider) SearchByCoordinates(term string, latitude float64, longitude float64, options model.SearchOptions) (model.PlaceSearchResult, error) {
+	response, err := provider.yelp.SearchByCoordinates(term, latitude, longitude, options)
+	if err != nil {
+		return model.PlaceSearchResult{}, err
+	}
+
+	return yelpSearchResult(response), nil
+}
+
+func (provider yelpPlaceProvider) GetPlace(id string) (model.PlaceDetails, error) {
+	details, err := provider.yelp.GetBusiness(id)
+	if err != nil {
+		return model.PlaceDetails{}, err
+	}
+
+	place := model.PlaceDetails{
+		Place:  yelpPlace(details.Business),
+		Photos: details.Photos,
+	}
+
+	if details.DisplayPhone != "" {
+		place.Phone = details.DisplayPhone
+	}
+
+	if len(details.Hours) > 0 {
+		place.Hours = details.Hours[0].Open
+		place.IsOpenNow = details.Hours[0].IsOpenNow
+	}
+
+	return place, nil
+}
+
+func (provider yelpPlaceProvider) GetReviews(id string) ([]model.PlaceReview, error) {
+	response, err := provider.yelp.GetReviews(id)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews := []model.PlaceReview{}
+	for _, review := range response.Reviews {
+		// Yelp gives the time in the business's local time zone without saying
+		// which, close enough for showing the date.
+		created, _ := time.Parse(yelpReviewTimeLayout, review.TimeCreated)
+
+		reviews = append(reviews, model.PlaceReview{
+			Rating:  review.Rating,
+			Text:    review.Text,
+			Author:  review.User.Name,
+			Created: created,
+			URL:     review.URL,
+		})
+	}
+
+	return reviews, nil
+}
+
+func yelpSearchResult(response model.SearchResponse) model.PlaceSearchResult {
+	result := model.PlaceSearchResult{
+		Total:  response.Total,
+		Places: []model.Place{},
+	}
+
+	// Yelp won't return results past YelpMaxResults however many it found
+	if result.Total > YelpMaxResults {
+		result.Total = YelpMaxResults
+	}
+
+	for _, business := range response.Businesses {
+		result.Places = append(result.Places, yelpPlace(business))
+	}
+
+	return result
+}
+
+func yelpPlace(business model.Business) model.Place {
+	categories := []string{}
+	for _, category := range business.Categories {
+		categories = append(categories, category.Title)
+	}
+
+	address := business.Location.DisplayAddress
+	if len(address) == 0 {
+		for _, line := range []string{business.Location.Address1, business.Location.Address2, business.Location.Address3, business.Location.City} {
+			if line != "" {
+				address = append(address, line)
+			}
+		}
+	}
+
+	return model.Place{
+		ID:          business.ID,
+		Provider:    YelpPlaceProvider,
+		Name:        business.Name,
+		URL:         business.URL,
+		Rating:      business.Rating,
+		ReviewCount: business.ReviewCount,
+		Price:       business.Price,
+		Phone:       business.Phone,
+		Categories:  categories,
+		Address:     address,
+		Coordinates: business.Coordinates,
+		Distance:    float64(business.Distance),
+		ImageURL:    business.ImageURL,
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+const (
+	commandPrefix       = "/"
+	botMentionSeparator = "@"
+	quote               = '"'
+)
+
+// token is a word of a user's message, or a "quoted phrase" which is kept
+// together and never treated as a keyword.
+type token struct {
+	text   string
+	quoted bool
+}
+
+// is reports whether the token is an unquoted match for one of words,
+// ignoring case.
+func (t token) is(words ...string) bool {
+	if t.quoted {
+		return false
+	}
+
+	for _, word := range words {
+		if strings.EqualFold(t.text, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseQuery parses a message such as
+//
+//	/search@FoodHelperBot cheap "dim sum" in the mission in sf open now
+//
+// into its command, the bot it mentions, and for searches the term, location
+// and modifiers. The grammar is
+//
+//	query    = [command["@"bot]] {word | modifier} [("in" location) | "nearby" | "near me"]
+//	location = {word | modifier}
+//
+// where the first unquoted "in" starts the location, so "food in the mission
+// in sf" searches for "food" in "the mission in sf". A search without a
+// location is a search near the user, and one with a location isn't, even if
+// it also says "nearby".
+func parseQuery(text string) model.Query {
+	var query model.Query
+
+	tokens := tokenize(text)
+	if len(tokens) > 0 && !tokens[0].quoted && strings.HasPrefix(tokens[0].text, commandPrefix) {
+		query.Command = strings.ToLower(tokens[0].text)
+
+		if mentionIndex := strings.Index(query.Command, botMentionSeparator); mentionIndex > -1 {
+			query.BotMention = tokens[0].text[mentionIndex+1:]
+			query.Command = query.Command[0:mentionIndex]
+		}
+
+		tokens = tokens[1:]
+	}
+
+	query.Arguments = joinTokens(tokens, true)
+
+	tokens, query.Options = parseModifiers(tokens)
+
+	termTokens := tokens
+	for i, t := range tokens {
+		if t.is(searchLocationKeyword) {
+			termTokens = tokens[0:i]
+			query.Location = joinTokens(tokens[i+1:], false)
+			break
+		}
+	}
+
+	query.Term = joinTokens(removeNearby(termTokens), false)
+
+	// A search is near the user unless it gives a location, even if it also
+	// says "nearby"
+	query.Nearby = query.Location == ""
+
+	return query
+}
+
+// tokenize splits text on whitespace, keeping double quoted phrases as single
+// tokens. An unterminated quote runs to the end of the text.
+func tokenize(text string) []token {
+	tokens := []token{}
+
+	var current strings.Builder
+	inQuote := false
+
+	flush := func(quoted bool) {
+		if current.Len() > 0 || quoted {
+			tokens = append(tokens, token{text: current.String(), quoted: quoted})
+		}
+		current.Reset()
+	}
+
+	for _, r := range text {
+		switch {
+		case r == quote && inQuote:
+			inQuote = false
+			flush(true)
+		case r == quote:
+			flush(false)
+			inQuote = true
+		case unicode.IsSpace(r) && !inQuote:
+			flush(false)
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	flush(inQuote)
+
+	return tokens
+}
+
+// removeNearby drops "nearby" and "near me" from tokens.
+func removeNearby(tokens []token) []token {
+	remaining := []token{}
+
+	for i := 0; i < len(tokens); i++ {
+		switch {
+		case tokens[i].is(nearbyKeyword):
+		case tokens[i].is(nearKeyword) && i+1 < len(tokens) && tokens[i+1].is(meKeyword):
+			i++
+		default:
+			remaining = append(remaining, tokens[i])
+		}
+	}
+
+	return remaining
+}
+
+// joinTokens joins tokens with single spaces, putting quoted phrases back in
+// quotes when requoted is set.
+func joinTokens(tokens []token, requote bool) string {
+	words := []string{}
+	for _, t := range tokens {
+		if t.quoted && requote {
+			words = append(words, string(quote)+t.text+string(quote))
+		} else if t.text != "" {
+			words = append(words, t.text)
+		}
+	}
+
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected model.Query
+	}{
+		{
+			text:     "",
+			expected: model.Query{Nearby: true},
+		},
+		{
+			text: "hello there",
+			expected: model.Query{
+				Arguments: "hello there",
+				Term:      "hello there",
+				Nearby:    true,
+			},
+		},
+		{
+			text:     "/search",
+			expected: model.Query{Command: "/search", Nearby: true},
+		},
+		{
+			text: "/search pizza",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "pizza",
+				Term:      "pizza",
+				Nearby:    true,
+			},
+		},
+		{
+			text: "/search food in the mission in sf",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "food in the mission in sf",
+				Term:      "food",
+				Location:  "the mission in sf",
+			},
+		},
+		{
+			text: "/search@FoodHelperBot pizza",
+			expected: model.Query{
+				Command:    "/search",
+				BotMention: "FoodHelperBot",
+				Arguments:  "pizza",
+				Term:       "pizza",
+				Nearby:     true,
+			},
+		},
+		{
+			text: "/search@FoodHelperBot",
+			expected: model.Query{
+				Command:    "/search",
+				BotMention: "FoodHelperBot",
+				Nearby:     true,
+			},
+		},
+		{
+			text: "/SEARCH Pizza IN Boston",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "Pizza IN Boston",
+				Term:      "Pizza",
+				Location:  "Boston",
+			},
+		},
+		{
+			text: "/search in sf",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "in sf",
+				Location:  "sf",
+			},
+		},
+		{
+			text: `/search "dim sum" in sf`,
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: `"dim sum" in sf`,
+				Term:      "dim sum",
+				Location:  "sf",
+			},
+		},
+		{
+			text: `/search "in n out" nearby`,
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: `"in n out" nearby`,
+				Term:      "in n out",
+				Nearby:    true,
+			},
+		},
+		{
+			text: `/search "open now"`,
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: `"open now"`,
+				Term:      "open now",
+				Nearby:    true,
+			},
+		},
+		{
+			text: "/search tacos nearby",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "tacos nearby",
+				Term:      "tacos",
+				Nearby:    true,
+			},
+		},
+		{
+			text: "/search tacos near me",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "tacos near me",
+				Term:      "tacos",
+				Nearby:    true,
+			},
+		},
+		{
+			text: "/search pizza near me in sf",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "pizza near me in sf",
+				Term:      "pizza",
+				Location:  "sf",
+			},
+		},
+		{
+			text: "/search nearby tacos in oakland",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "nearby tacos in oakland",
+				Term:      "tacos",
+				Location:  "oakland",
+			},
+		},
+		{
+			text: "/search near the park",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "near the park",
+				Term:      "near the park",
+				Nearby:    true,
+			},
+		},
+		{
+			text: "/search ramen within 1 km",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "ramen within 1 km",
+				Term:      "ramen",
+				Nearby:    true,
+				Options:   model.SearchOptions{Radius: 1000},
+			},
+		},
+		{
+			text: "/search pho sorted by review count in oakland",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "pho sorted by review count in oakland",
+				Term:      "pho",
+				Location:  "oakland",
+				Options:   model.SearchOptions{SortBy: "review_count"},
+			},
+		},
+		{
+			text: "/search $$ burgers",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "$$ burgers",
+				Term:      "burgers",
+				Nearby:    true,
+				Options:   model.SearchOptions{Price: []int{2}},
+			},
+		},
+		{
+			text: "/search burgers price:1,2",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "burgers price:1,2",
+				Term:      "burgers",
+				Nearby:    true,
+				Options:   model.SearchOptions{Price: []int{1, 2}},
+			},
+		},
+		{
+			text: "/search sushi in san jose open now",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "sushi in san jose open now",
+				Term:      "sushi",
+				Location:  "san jose",
+				Options:   model.SearchOptions{OpenNow: true},
+			},
+		},
+		{
+			text: "/search tacos in oakland within 2km sorted by rating",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "tacos in oakland within 2km sorted by rating",
+				Term:      "tacos",
+				Location:  "oakland",
+				Options:   model.SearchOptions{Radius: 2000, SortBy: "rating"},
+			},
+		},
+		{
+			text: "/search cheap hot and new tacos in sf",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "cheap hot and new tacos in sf",
+				Term:      "tacos",
+				Location:  "sf",
+				Options: model.SearchOptions{
+					Price:      []int{1, 2},
+					Attributes: []string{"hot_and_new"},
+				},
+			},
+		},
+		{
+			text: "/search pho in @office",
+			expected: model.Query{
+				Command:   "/search",
+				Arguments: "pho in @office",
+				Term:      "pho",
+				Location:  "@office",
+			},
+		},
+		{
+			text: "/details 3",
+			expected: model.Query{
+				Command:   "/details",
+				Arguments: "3",
+				Term:      "3",
+				Nearby:    true,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		query := parseQuery(test.text)
+		if !reflect.DeepEqual(query, test.expected) {
+			t.Errorf("parseQuery(%q)\n got: %+v\nwant: %+v", test.text, query, test.expected)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []token
+	}{
+		{
+			text:     "",
+			expected: []token{},
+		},
+		{
+			text:     "   ",
+			expected: []token{},
+		},
+		{
+			text:     "  tacos   in sf ",
+			expected: []token{{text: "tacos"}, {text: "in"}, {text: "sf"}},
+		},
+		{
+			text:     "tab\tand\nnewline",
+			expected: []token{{text: "tab"}, {text: "and"}, {text: "newline"}},
+		},
+		{
+			text:     `a "dim sum" b`,
+			expected: []token{{text: "a"}, {text: "dim sum", quoted: true}, {text: "b"}},
+		},
+		{
+			text:     `a"dim sum"b`,
+			expected: []token{{text: "a"}, {text: "dim sum", quoted: true}, {text: "b"}},
+		},
+		{
+			text:     `""`,
+			expected: []token{{text: "", quoted: true}},
+		},
+		{
+			text:     `pho "in the mission`,
+			expected: []token{{text: "pho"}, {text: "in the mission", quoted: true}},
+		},
+		{
+			text:     `"  spaced  out  "`,
+			expected: []token{{text: "  spaced  out  ", quoted: true}},
+		},
+	}
+
+	for _, test := range tests {
+		tokens := tokenize(test.text)
+		if !reflect.DeepEqual(tokens, test.expected) {
+			t.Errorf("tokenize(%q)\n got: %+v\nwant: %+v", test.text, tokens, test.expected)
+		}
+	}
+}
+
+func TestParseModifiers(t *testing.T) {
+	tests := []struct {
+		text      string
+		remaining string
+		expected  model.SearchOptions
+	}{
+		{text: "tacos", remaining: "tacos"},
+		{text: "cheap", expected: model.SearchOptions{Price: []int{1, 2}}},
+		{text: "inexpensive", expected: model.SearchOptions{Price: []int{1, 2}}},
+		{text: "fancy", expected: model.SearchOptions{Price: []int{3, 4}}},
+		{text: "$", expected: model.SearchOptions{Price: []int{1}}},
+		{text: "$$", expected: model.SearchOptions{Price: []int{2}}},
+		{text: "$$$$", expected: model.SearchOptions{Price: []int{4}}},
+		{text: "$$$$$", remaining: "$$$$$"},
+		{text: "cheap tacos $$", remaining: "tacos", expected: model.SearchOptions{Price: []int{1, 2}}},
+		{text: "price:1,2", expected: model.SearchOptions{Price: []int{1, 2}}},
+		{text: "price:$,$$$", expected: model.SearchOptions{Price: []int{1, 3}}},
+		{text: "price:5"},
+		{text: "open now", expected: model.SearchOptions{OpenNow: true}},
+		{text: "Open Now", expected: model.SearchOptions{OpenNow: true}},
+		{text: "open:now", expected: model.SearchOptions{OpenNow: true}},
+		{text: "open late", remaining: "open late"},
+		{text: "within 1 km", expected: model.SearchOptions{Radius: 1000}},
+		{text: "within 1km", expected: model.SearchOptions{Radius: 1000}},
+		{text: "within 1.5km", expected: model.SearchOptions{Radius: 1500}},
+		{text: "within 500 m", expected: model.SearchOptions{Radius: 500}},
+		{text: "within 2 miles", expected: model.SearchOptions{Radius: 3218}},
+		{text: "within 100km", expected: model.SearchOptions{Radius: YelpMaxRadius}},
+		{text: "within reach", remaining: "within reach"},
+		{text: "within 5 minutes", remaining: "within 5 minutes"},
+		{text: "radius:500", expected: model.SearchOptions{Radius: 500}},
+		{text: "radius:1.5km", expected: model.SearchOptions{Radius: 1500}},
+		{text: "sorted by review count", expected: model.SearchOptions{SortBy: "review_count"}},
+		{text: "sorted by reviews", expected: model.SearchOptions{SortBy: "review_count"}},
+		{text: "sort by rating", expected: model.SearchOptions{SortBy: "rating"}},
+		{text: "sorted by distance", expected: model.SearchOptions{SortBy: "distance"}},
+		{text: "sorted by magic", remaining: "sorted by magic"},
+		{text: "sort:distance", expected: model.SearchOptions{SortBy: "distance"}},
+		{text: "hot and new", expected: model.SearchOptions{Attributes: []string{"hot_and_new"}}},
+		{text: "category:ramen,Pho", expected: model.SearchOptions{Categories: []string{"ramen", "pho"}}},
+		{text: "attributes:deals,hot_and_new", expected: model.SearchOptions{Attributes: []string{"deals", "hot_and_new"}}},
+		{text: `"cheap" eats`, remaining: `"cheap" eats`},
+		{text: `within "1 km"`, remaining: `within "1 km"`},
+		{
+			text:      "cheap ramen in sf open now within 2 km",
+			remaining: "ramen in sf",
+			expected:  model.SearchOptions{Price: []int{1, 2}, OpenNow: true, Radius: 2000},
+		},
+	}
+
+	for _, test := range tests {
+		tokens, options := parseModifiers(tokenize(test.text))
+		if remaining := joinTokens(tokens, true); remaining != test.remaining {
+			t.Errorf("parseModifiers(%q) left %q, want %q", test.text, remaining, test.remaining)
+		}
+
+		if !reflect.DeepEqual(options, test.expected) {
+			t.Errorf("parseModifiers(%q)\n got: %+v\nwant: %+v", test.text, options, test.expected)
+		}
+	}
+}
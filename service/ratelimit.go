@@ -0,0 +1,97 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket allows bursts of up to burst events, refilling at rate events
+// per second. The rate must be positive, callers that allow turning limiting
+// off do so without a bucket, see newKeyedThrottle.
+type tokenBucket struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+// Reserve takes a token and returns how long the caller has to wait before
+// using it, 0 if one was available.
+func (bucket *tokenBucket) Reserve() time.Duration {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	bucket.refill()
+	bucket.tokens--
+
+	if bucket.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-bucket.tokens / bucket.rate * float64(time.Second))
+}
+
+// Allow takes a token if one is available right now.
+func (bucket *tokenBucket) Allow() bool {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	bucket.refill()
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+
+	return true
+}
+
+// refill adds the tokens earned since the last call. Callers must hold the
+// lock.
+func (bucket *tokenBucket) refill() {
+	now := bucket.now()
+	if !bucket.last.IsZero() {
+		bucket.tokens += now.Sub(bucket.last).Seconds() * bucket.rate
+		if bucket.tokens > bucket.burst {
+			bucket.tokens = bucket.burst
+		}
+	}
+
+	bucket.last = now
+}
+
+// Delay returns how long until a token is available, 0 if one is available
+// now. It doesn't take the token.
+func (bucket *tokenBucket) Delay() time.Duration {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	bucket.refill()
+	if bucket.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - bucket.tokens) / bucket.rate * float64(time.Second))
+}
+
+// Full reports whether the bucket has refilled completely, so it behaves the
+// same as a new one and can be dropped.
+func (bucket *tokenBucket) Full() bool {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	bucket.refill()
+
+	return bucket.tokens >= bucket.burst
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+// savedPlacePrefix marks a search location as the name of a saved place, as
+// in "/search pho in @office".
+const savedPlacePrefix = "@"
+
+var savedPlaceNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// setPlaceNeedsLocation asks for the user's location when they only gave a
+// name, "/setplace office", rather than a name and an address.
+func setPlaceNeedsLocation(query model.Query) bool {
+	name, address := splitSetPlaceArguments(query.Arguments)
+	return name != "" && address == ""
+}
+
+func (svc botService) handleSetPlace(request CommandRequest, response *model.Message) {
+	name, address := splitSetPlaceArguments(request.Query.Arguments)
+	if !savedPlaceNamePattern.MatchString(name) {
+		response.Text = SetPlaceUsageResponse
+		return
+	}
+
+	place := model.SavedPlace{Address: address}
+	if address == "" {
+		if request.Location == nil {
+			response.Text = SetPlaceUsageResponse
+			return
+		}
+
+		place.Coordinates = *request.Location
+	}
+
+	svc.updateUserSession(userSessionKey(request.Message.Message.From.ID), func(session *model.UserLocationInfo) {
+		if session.SavedPlaces == nil {
+			session.SavedPlaces = make(map[string]model.SavedPlace)
+		}
+
+		session.SavedPlaces[name] = place
+	})
+
+	log.Printf("[handleSetPlace] User %d saved place %s", request.Message.Message.From.ID, name)
+
+	response.Text = fmt.Sprintf(PlaceSavedResponseFormat, name, name)
+}
+
+func (svc botService) handlePlaces(request CommandRequest, response *model.Message) {
+	places := svc.getUserSession(userSessionKey(request.Message.Message.From.ID)).SavedPlaces
+	if len(places) == 0 {
+		response.Text = NoSavedPlacesResponse
+		return
+	}
+
+	names := []string{}
+	for name := range places {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var placesString string
+	for _, name := range names {
+		placesString += fmt.Sprintf("%s%s: %s\n", savedPlacePrefix, name, describeSavedPlace(places[name]))
+	}
+
+	response.Text = placesString
+}
+
+// searchLocation works out where the search in request should run, either
+// the location the user shared, one of their saved places or the location
+// they typed. When it can't, it returns a message to send back instead.
+func (svc botService) searchLocation(request CommandRequest) (string, model.Coordinates, string) {
+	if request.Location != nil {
+		return "", *request.Location, ""
+	}
+
+	name, ok := savedPlaceName(request.Query.Location)
+	if !ok {
+		return request.Query.Location, model.Coordinates{}, ""
+	}
+
+	place, ok := svc.getUserSession(userSessionKey(request.Message.Message.From.ID)).SavedPlaces[name]
+	if !ok {
+		return "", model.Coordinates{}, fmt.Sprintf(UnknownPlaceResponseFormat, name)
+	}
+
+	log.Printf("[searchLocation] Using saved place %s", name)
+
+	return place.Address, place.Coordinates, ""
+}
+
+// splitSetPlaceArguments splits "/setplace" arguments into the place's name,
+// lower cased and without any "@", and the address after it.
+func splitSetPlaceArguments(arguments string) (string, string) {
+	fields := strings.Fields(arguments)
+	if len(fields) == 0 {
+		return "", ""
+	}
+
+	name := strings.ToLower(strings.TrimPrefix(fields[0], savedPlacePrefix))
+
+	return name, strings.Join(fields[1:], " ")
+}
+
+// savedPlaceName returns the name in a search location like "@office".
+func savedPlaceName(location string) (string, bool) {
+	if !strings.HasPrefix(location, savedPlacePrefix) || strings.ContainsAny(location, " \t") {
+		return "", false
+	}
+
+	return strings.ToLower(strings.TrimPrefix(location, savedPlacePrefix)), true
+}
+
+func describeSavedPlace(place model.SavedPlace) string {
+	if place.Address != "" {
+		return place.Address
+	}
+
+	return fmt.Sprintf("shared location (%.5f, %.5f)", place.Coordinates.Latitude, place.Coordinates.Longitude)
+}
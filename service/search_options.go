@@ -0,0 +1,216 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+const optionValueSeparator = ","
+
+// Words users might type for each Yelp sort_by value
+var sortByNames = map[string]string{
+	"best match":   "best_match",
+	"best_match":   "best_match",
+	"rating":       "rating",
+	"review count": "review_count",
+	"review_count": "review_count",
+	"reviews":      "review_count",
+	"distance":     "distance",
+}
+
+// parseModifiers pulls search modifiers out of tokens, returning the tokens
+// that are left. Modifiers are either phrases such as "cheap", "$$", "open
+// now", "within 1km", "sorted by rating" and "hot and new", or key:value
+// tokens such as "price:1,2", "open:now", "radius:500m", "sort:distance",
+// "category:ramen" and "attributes:hot_and_new,deals". Quoted tokens are
+// never modifiers.
+func parseModifiers(tokens []token) ([]token, model.SearchOptions) {
+	var options model.SearchOptions
+
+	remaining := []token{}
+	for i := 0; i < len(tokens); i++ {
+		if consumed := parseModifier(tokens[i:], &options); consumed > 0 {
+			i += consumed - 1
+		} else {
+			remaining = append(remaining, tokens[i])
+		}
+	}
+
+	return remaining, options
+}
+
+// parseModifier applies the modifier at the start of tokens to options and
+// returns how many tokens it used, or 0 if tokens doesn't start with one.
+func parseModifier(tokens []token, options *model.SearchOptions) int {
+	first := tokens[0]
+	if first.quoted {
+		return 0
+	}
+
+	next := func(i int) token {
+		if i < len(tokens) {
+			return tokens[i]
+		}
+		return token{quoted: true}
+	}
+
+	switch {
+	case parseSearchOptionToken(first.text, options):
+		return 1
+	case first.is("open") && next(1).is("now"):
+		options.OpenNow = true
+		return 2
+	case first.is("hot") && next(1).is("and") && next(2).is("new"):
+		options.Attributes = appendUnique(options.Attributes, "hot_and_new")
+		return 3
+	case first.is("cheap", "inexpensive"):
+		options.Price = appendUniquePrices(options.Price, 1, 2)
+		return 1
+	case first.is("expensive", "fancy", "upscale"):
+		options.Price = appendUniquePrices(options.Price, 3, 4)
+		return 1
+	case len(first.text) <= 4 && first.text != "" && strings.Trim(first.text, "$") == "":
+		options.Price = appendUniquePrices(options.Price, len(first.text))
+		return 1
+	case first.is("within"):
+		// Either "within 1km" or "within 1 km"
+		if number, unit := splitDistance(next(1).text); !next(1).quoted && unit != "" {
+			if radius, ok := parseDistance(number, unit); ok {
+				options.Radius = radius
+				return 2
+			}
+		} else if !next(1).quoted && !next(2).quoted {
+			if radius, ok := parseDistance(next(1).text, next(2).text); ok {
+				options.Radius = radius
+				return 3
+			}
+		}
+	case first.is("sort", "sorted") && next(1).is("by"):
+		if sortBy, ok := sortByNames[strings.ToLower(next(2).text+" "+next(3).text)]; ok && !next(2).quoted && !next(3).quoted {
+			options.SortBy = sortBy
+			return 4
+		} else if sortBy, ok := sortByNames[strings.ToLower(next(2).text)]; ok && !next(2).quoted {
+			options.SortBy = sortBy
+			return 3
+		}
+	}
+
+	return 0
+}
+
+// parseSearchOptionToken applies a single key:value token to options,
+// reporting whether the token was a recognized option.
+func parseSearchOptionToken(text string, options *model.SearchOptions) bool {
+	separatorIndex := strings.Index(text, ":")
+	if separatorIndex < 1 {
+		return false
+	}
+
+	key := strings.ToLower(text[0:separatorIndex])
+	value := text[separatorIndex+1:]
+
+	switch key {
+	case "price":
+		for _, price := range strings.Split(value, optionValueSeparator) {
+			if strings.Trim(price, "$") == "" && len(price) >= 1 && len(price) <= 4 {
+				options.Price = appendUniquePrices(options.Price, len(price))
+			} else if tier, err := strconv.Atoi(price); err == nil && tier >= 1 && tier <= 4 {
+				options.Price = appendUniquePrices(options.Price, tier)
+			}
+		}
+	case "open", "open_now":
+		switch strings.ToLower(value) {
+		case "now", "true", "yes":
+			options.OpenNow = true
+		}
+	case "radius", "within":
+		number, unit := splitDistance(value)
+		if unit == "" {
+			unit = "m"
+		}
+
+		if radius, ok := parseDistance(number, unit); ok {
+			options.Radius = radius
+		}
+	case "sort", "sort_by":
+		if sortBy, ok := sortByNames[strings.ToLower(value)]; ok {
+			options.SortBy = sortBy
+		}
+	case "category", "categories":
+		for _, category := range strings.Split(value, optionValueSeparator) {
+			if category != "" {
+				options.Categories = appendUnique(options.Categories, strings.ToLower(category))
+			}
+		}
+	case "attribute", "attributes":
+		for _, attribute := range strings.Split(value, optionValueSeparator) {
+			if attribute != "" {
+				options.Attributes = appendUnique(options.Attributes, strings.ToLower(attribute))
+			}
+		}
+	default:
+		return false
+	}
+
+	return true
+}
+
+// splitDistance splits a distance like "1.5km" into its number and unit.
+func splitDistance(text string) (string, string) {
+	number := strings.TrimRight(text, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	return number, text[len(number):]
+}
+
+// parseDistance converts a distance such as "1.5" "km" to whole meters.
+func parseDistance(number string, unit string) (int, bool) {
+	distance, err := strconv.ParseFloat(number, 64)
+	if err != nil || distance <= 0 {
+		return 0, false
+	}
+
+	switch strings.ToLower(unit) {
+	case "km", "kilometer", "kilometers":
+		distance *= 1000
+	case "mi", "mile", "miles":
+		distance *= 1609.34
+	case "m", "meter", "meters":
+	default:
+		return 0, false
+	}
+
+	if distance > YelpMaxRadius {
+		distance = YelpMaxRadius
+	}
+
+	return int(distance), true
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+
+	return append(values, value)
+}
+
+func appendUniquePrices(prices []int, tiers ...int) []int {
+	for _, tier := range tiers {
+		found := false
+		for _, existing := range prices {
+			if existing == tier {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			prices = append(prices, tier)
+		}
+	}
+
+	return prices
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/zachvanuum/FoodHelperBot/util"
+)
+
+const defaultSeenUpdatesSize = 10000
+
+// SeenUpdates remembers the IDs of the last size updates the bot accepted, so
+// an update Telegram sends again isn't answered twice. With a path it's saved
+// every saveInterval and on Close, so it also covers updates Telegram resends
+// after a restart.
+type SeenUpdates struct {
+	lock  sync.Mutex
+	ids   []int64
+	next  int
+	index map[int64]bool
+	dirty bool
+
+	path string
+	done chan struct{}
+	wait sync.WaitGroup
+}
+
+// NewSeenUpdates loads the saved IDs from path, if it's set and the file
+// exists.
+func NewSeenUpdates(size int, path string, saveInterval time.Duration) (*SeenUpdates, error) {
+	if size < 1 {
+		size = defaultSeenUpdatesSize
+	}
+
+	seen := &SeenUpdates{
+		ids:   make([]int64, 0, size),
+		index: make(map[int64]bool),
+		path:  path,
+		done:  make(chan struct{}),
+	}
+
+	if path == "" {
+		return seen, nil
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read seen updates %s: %s", path, err.Error())
+	}
+
+	if len(contents) > 0 {
+		var ids []int64
+		if err := json.Unmarshal(contents, &ids); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal seen updates %s: %s", path, err.Error())
+		}
+
+		for _, id := range ids {
+			seen.add(id)
+		}
+		seen.dirty = false
+	}
+
+	if saveInterval > 0 {
+		seen.wait.Add(1)
+		go seen.saveEvery(saveInterval)
+	}
+
+	return seen, nil
+}
+
+func (seen *SeenUpdates) Contains(id int64) bool {
+	seen.lock.Lock()
+	defer seen.lock.Unlock()
+
+	return seen.index[id]
+}
+
+func (seen *SeenUpdates) Add(id int64) {
+	seen.lock.Lock()
+	defer seen.lock.Unlock()
+
+	if !seen.index[id] {
+		seen.add(id)
+	}
+}
+
+// add overwrites the oldest ID once the ring is full. Callers must hold the
+// lock.
+func (seen *SeenUpdates) add(id int64) {
+	if len(seen.ids) < cap(seen.ids) {
+		seen.ids = append(seen.ids, id)
+	} else {
+		delete(seen.index, seen.ids[seen.next])
+		seen.ids[seen.next] = id
+		seen.next = (seen.next + 1) % len(seen.ids)
+	}
+
+	seen.index[id] = true
+	seen.dirty = true
+}
+
+// Close stops saving periodically and saves one last time.
+func (seen *SeenUpdates) Close() error {
+	close(seen.done)
+	seen.wait.Wait()
+
+	return seen.save()
+}
+
+func (seen *SeenUpdates) saveEvery(interval time.Duration) {
+	defer seen.wait.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := seen.save(); err != nil {
+				log.Printf("[SeenUpdates] %s", err.Error())
+			}
+		case <-seen.done:
+			return
+		}
+	}
+}
+
+func (seen *SeenUpdates) save() error {
+	seen.lock.Lock()
+	defer seen.lock.Unlock()
+
+	if seen.path == "" || !seen.dirty {
+		return nil
+	}
+
+	// Oldest first, so they're forgotten in the same order after loading
+	ids := append(append([]int64{}, seen.ids[seen.next:]...), seen.ids[:seen.next]...)
+
+	contents, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("failed to marshal seen updates: %s", err.Error())
+	}
+
+	if err := util.WriteFileAtomic(seen.path, contents); err != nil {
+		return fmt.Errorf("failed to save seen updates: %s", err.Error())
+	}
+
+	seen.dirty = false
+
+	return nil
+}
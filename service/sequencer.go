@@ -0,0 +1,89 @@
+package service
+
+import (
+	"sort"
+	"sync"
+)
+
+// chatSequencer runs work for a single chat one update at a time. Updates for
+// different chats run in parallel. When several updates for the same chat are
+// waiting, the one with the lowest update ID runs next, so a burst of webhook
+// requests is handled in the order Telegram assigned rather than the order the
+// goroutines happened to be scheduled.
+type chatSequencer struct {
+	lock  sync.Mutex
+	chats map[int64]*chatQueue
+}
+
+type chatQueue struct {
+	busy    bool
+	waiting []pendingUpdate
+}
+
+type pendingUpdate struct {
+	updateID int64
+	ready    chan struct{}
+}
+
+func newChatSequencer() *chatSequencer {
+	return &chatSequencer{
+		chats: make(map[int64]*chatQueue),
+	}
+}
+
+// Do blocks until no other update for chatID is running, then calls fn.
+func (seq *chatSequencer) Do(chatID int64, updateID int64, fn func()) {
+	seq.acquire(chatID, updateID)
+	defer seq.release(chatID)
+
+	fn()
+}
+
+func (seq *chatSequencer) acquire(chatID int64, updateID int64) {
+	seq.lock.Lock()
+
+	queue, ok := seq.chats[chatID]
+	if !ok {
+		queue = &chatQueue{}
+		seq.chats[chatID] = queue
+	}
+
+	if !queue.busy {
+		queue.busy = true
+		seq.lock.Unlock()
+		return
+	}
+
+	pending := pendingUpdate{
+		updateID: updateID,
+		ready:    make(chan struct{}),
+	}
+
+	i := sort.Search(len(queue.waiting), func(i int) bool {
+		return queue.waiting[i].updateID > updateID
+	})
+	queue.waiting = append(queue.waiting, pendingUpdate{})
+	copy(queue.waiting[i+1:], queue.waiting[i:])
+	queue.waiting[i] = pending
+
+	seq.lock.Unlock()
+
+	<-pending.ready
+}
+
+// release hands the chat to the lowest waiting update, or forgets the chat
+// entirely when nothing is waiting so the map only holds active chats.
+func (seq *chatSequencer) release(chatID int64) {
+	seq.lock.Lock()
+	defer seq.lock.Unlock()
+
+	queue := seq.chats[chatID]
+	if len(queue.waiting) == 0 {
+		delete(seq.chats, chatID)
+		return
+	}
+
+	next := queue.waiting[0]
+	queue.waiting = queue.waiting[1:]
+	close(next.ready)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChatSequencerRunsWaitingUpdatesInOrder(t *testing.T) {
+	seq := newChatSequencer()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go seq.Do(1, 1, func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	var lock sync.Mutex
+	order := []int64{}
+
+	var wg sync.WaitGroup
+	for _, updateID := range []int64{7, 3, 9, 2, 5} {
+		wg.Add(1)
+		go func(updateID int64) {
+			defer wg.Done()
+
+			seq.Do(1, updateID, func() {
+				lock.Lock()
+				order = append(order, updateID)
+				lock.Unlock()
+			})
+		}(updateID)
+	}
+
+	waitForWaitingUpdates(t, seq, 1, 5)
+	close(release)
+	wg.Wait()
+
+	if expected := []int64{2, 3, 5, 7, 9}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("updates ran in order %v, want %v", order, expected)
+	}
+
+	seq.lock.Lock()
+	defer seq.lock.Unlock()
+	if len(seq.chats) != 0 {
+		t.Errorf("sequencer still holds %d chats after they finished", len(seq.chats))
+	}
+}
+
+func TestChatSequencerRunsChatsInParallel(t *testing.T) {
+	seq := newChatSequencer()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go seq.Do(1, 1, func() {
+		close(started)
+		<-release
+	})
+	<-started
+	defer close(release)
+
+	done := make(chan struct{})
+	go seq.Do(2, 2, func() {
+		close(done)
+	})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("an update for another chat waited on a busy chat")
+	}
+}
+
+// waitForWaitingUpdates waits until count updates are queued behind the one
+// running for chatID.
+func waitForWaitingUpdates(t *testing.T, seq *chatSequencer, chatID int64, count int) {
+	deadline := time.Now().Add(time.Second)
+
+	for time.Now().Before(deadline) {
+		seq.lock.Lock()
+		waiting := len(seq.chats[chatID].waiting)
+		seq.lock.Unlock()
+
+		if waiting == count {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("gave up waiting for %d updates to queue for chat %d", count, chatID)
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+const (
+	// Supported session store types, selected with the "session.store" config value
+	MemorySessionStore = "memory"
+	BoltSessionStore   = "bolt"
+	JSONSessionStore   = "json"
+
+	// Chat types that have more than one member
+	groupChatType      = "group"
+	supergroupChatType = "supergroup"
+)
+
+// SessionKey names a session. A private chat has a single session keyed by
+// its chat ID, which is also the user's ID, while each member of a group gets
+// their own session within it.
+type SessionKey string
+
+// SessionStore keeps each user's last command, search term and location so
+// they can finish a multi-message flow, such as sharing their location after
+// "/search tacos nearby".
+type SessionStore interface {
+	Get(key SessionKey) (model.UserLocationInfo, error)
+	Put(key SessionKey, info model.UserLocationInfo) error
+	Delete(key SessionKey) error
+	Close() error
+}
+
+// chatSessionKey is the key of a user's session in a chat.
+func chatSessionKey(chat model.ChatInfo, userID int64) SessionKey {
+	if !isGroupChat(chat) {
+		return userSessionKey(chat.ID)
+	}
+
+	return SessionKey(fmt.Sprintf("%d:%d", chat.ID, userID))
+}
+
+// userSessionKey is the key of the session in a user's private chat with the
+// bot, which also holds what they keep across chats such as saved places.
+func userSessionKey(userID int64) SessionKey {
+	return SessionKey(strconv.FormatInt(userID, 10))
+}
+
+func isGroupChat(chat model.ChatInfo) bool {
+	return chat.Type == groupChatType || chat.Type == supergroupChatType
+}
+
+// sessionLocks makes each read, change and write of a session atomic. Updates
+// are only handled one at a time per chat, but a user's own session is also
+// changed from the groups they're in.
+type sessionLocks struct {
+	lock  sync.Mutex
+	locks map[SessionKey]*sessionLock
+}
+
+type sessionLock struct {
+	sync.Mutex
+	holders int
+}
+
+func newSessionLocks() *sessionLocks {
+	return &sessionLocks{
+		locks: make(map[SessionKey]*sessionLock),
+	}
+}
+
+// Do calls fn while holding the lock for key. Locks are forgotten when no one
+// holds or waits for them, so the map only has sessions being changed.
+func (locks *sessionLocks) Do(key SessionKey, fn func()) {
+	locks.lock.Lock()
+	lock, ok := locks.locks[key]
+	if !ok {
+		lock = &sessionLock{}
+		locks.locks[key] = lock
+	}
+	lock.holders++
+	locks.lock.Unlock()
+
+	lock.Lock()
+	defer func() {
+		lock.Unlock()
+
+		locks.lock.Lock()
+		lock.holders--
+		if lock.holders == 0 {
+			delete(locks.locks, key)
+		}
+		locks.lock.Unlock()
+	}()
+
+	fn()
+}
+
+// NewSessionStore creates the store named by storeType. The path is the file
+// backing the bolt and json stores and is ignored for the memory store.
+func NewSessionStore(storeType string, path string) (SessionStore, error) {
+	switch storeType {
+	case MemorySessionStore, "":
+		return NewMemorySessionStore(), nil
+	case BoltSessionStore:
+		return NewBoltSessionStore(path)
+	case JSONSessionStore:
+		return NewJSONSessionStore(path)
+	default:
+		return nil, fmt.Errorf("unknown session store type %s", storeType)
+	}
+}
+
+type memorySessionStore struct {
+	lock     sync.RWMutex
+	sessions map[SessionKey]model.UserLocationInfo
+}
+
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{
+		sessions: make(map[SessionKey]model.UserLocationInfo),
+	}
+}
+
+func (store *memorySessionStore) Get(key SessionKey) (model.UserLocationInfo, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return store.sessions[key].Copy(), nil
+}
+
+func (store *memorySessionStore) Put(key SessionKey, info model.UserLocationInfo) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.sessions[key] = info.Copy()
+
+	return nil
+}
+
+func (store *memorySessionStore) Delete(key SessionKey) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	delete(store.sessions, key)
+
+	return nil
+}
+
+func (store *memorySessionStore) Close() error {
+	return nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+var sessionsBucket = []byte("sessions")
+
+type boltSessionStore struct {
+	db *bolt.DB
+}
+
+// NewBoltSessionStore opens, or creates, a BoltDB file at path to hold sessions.
+func NewBoltSessionStore(path string) (SessionStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt session store %s: %s", path, err.Error())
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create sessions bucket in %s: %s", path, err.Error())
+	}
+
+	return &boltSessionStore{db: db}, nil
+}
+
+func (store *boltSessionStore) Get(key SessionKey) (model.UserLocationInfo, error) {
+	var info model.UserLocationInfo
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(sessionsBucket).Get([]byte(key))
+		if value == nil {
+			return nil
+		}
+
+		return json.Unmarshal(value, &info)
+	})
+	if err != nil {
+		return info, fmt.Errorf("failed to read session %s: %s", key, err.Error())
+	}
+
+	return info, nil
+}
+
+func (store *boltSessionStore) Put(key SessionKey, info model.UserLocationInfo) error {
+	value, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session %s: %s", key, err.Error())
+	}
+
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(key), value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write session %s: %s", key, err.Error())
+	}
+
+	return nil
+}
+
+func (store *boltSessionStore) Delete(key SessionKey) error {
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(key))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete session %s: %s", key, err.Error())
+	}
+
+	return nil
+}
+
+func (store *boltSessionStore) Close() error {
+	return store.db.Close()
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+	"github.com/zachvanuum/FoodHelperBot/util"
+)
+
+// jsonSessionStore keeps every session in memory and rewrites the whole file
+// on each change, which is fine for the handful of chats a bot like this sees.
+type jsonSessionStore struct {
+	lock     sync.RWMutex
+	path     string
+	sessions map[SessionKey]model.UserLocationInfo
+}
+
+// NewJSONSessionStore loads sessions from the JSON file at path, starting
+// empty if the file does not exist yet.
+func NewJSONSessionStore(path string) (SessionStore, error) {
+	store := &jsonSessionStore{
+		path:     path,
+		sessions: make(map[SessionKey]model.UserLocationInfo),
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read json session store %s: %s", path, err.Error())
+	}
+
+	if len(contents) > 0 {
+		if err := json.Unmarshal(contents, &store.sessions); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal json session store %s: %s", path, err.Error())
+		}
+	}
+
+	return store, nil
+}
+
+func (store *jsonSessionStore) Get(key SessionKey) (model.UserLocationInfo, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return store.sessions[key].Copy(), nil
+}
+
+func (store *jsonSessionStore) Put(key SessionKey, info model.UserLocationInfo) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.sessions[key] = info.Copy()
+
+	return store.save()
+}
+
+func (store *jsonSessionStore) Delete(key SessionKey) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	delete(store.sessions, key)
+
+	return store.save()
+}
+
+func (store *jsonSessionStore) Close() error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	return store.save()
+}
+
+// save rewrites the whole file. Callers must hold the lock.
+func (store *jsonSessionStore) save() error {
+	contents, err := json.Marshal(store.sessions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sessions: %s", err.Error())
+	}
+
+	if err := util.WriteFileAtomic(store.path, contents); err != nil {
+		return fmt.Errorf("failed to save sessions: %s", err.Error())
+	}
+
+	return nil
+}
@@ -1,35 +1,51 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
-	"net/http"
-	"net/url"
 	"os"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/zachvanuum/FoodHelperBot/model"
-	"github.com/zachvanuum/FoodHelperBot/util"
+	"github.com/zachvanuum/FoodHelperBot/telegram"
+)
+
+const (
+	pollRetryDelay            = 5 * time.Second
+	defaultPollTimeoutSeconds = 30
 )
 
 type TelegramService interface {
 	GetMe() (model.BotInfo, error)
-	RegisterWebhook(url string) error
+	RegisterWebhook(url string, secretToken string) error
+	DeleteWebhook() error
+	GetWebhookInfo() (model.WebhookInfo, error)
+	SetMyCommands(commands []model.BotCommand) error
+	GetUpdates(ctx context.Context, offset int64, timeout int) ([]model.ReceivedMessage, error)
+	PollUpdates(ctx context.Context)
 	RespondToMessage(model.ReceivedMessage) error
 }
 
 type telegramService struct {
-	Token       string
-	YelpService YelpService
-	BotService  BotService
+	Token      string
+	Places     PlaceProvider
+	Sessions   SessionStore
+	BotService BotService
+
+	client *telegram.Client
 }
 
-func NewTelegramService(token string, yelpService YelpService) TelegramService {
+func NewTelegramService(token string, places PlaceProvider, sessions SessionStore) TelegramService {
+	outbound := newOutboundClient("telegram", "telegram")
+	outbound.resendable = telegram.IsResendable
+
 	service := telegramService{
-		Token:       token,
-		YelpService: yelpService,
+		Token:    token,
+		Places:   places,
+		Sessions: sessions,
+		client:   telegram.NewClient(viper.GetString("telegram.base_url"), token, outbound),
 	}
 
 	botService := service.setupBotService()
@@ -45,76 +61,148 @@ func (svc telegramService) setupBotService() BotService {
 		os.Exit(1)
 	}
 
-	webhookURL := viper.GetString("self_webhook_url")
-	if err := svc.RegisterWebhook(webhookURL); err != nil {
-		log.Fatalf("[setupBot] Failed to register webhook for bot using url %s", webhookURL)
+	bot := NewTelegramBot(botInfo, svc.Places, svc.Sessions, svc)
+
+	// Publishing the command list only affects Telegram's suggestions, so the
+	// bot still starts if it fails.
+	if err := svc.SetMyCommands(bot.Commands()); err != nil {
+		log.Printf("[setupBotService] Failed to publish commands: %s", err.Error())
 	}
 
-	return NewTelegramBot(botInfo, svc.YelpService)
+	return bot
 }
 
 func (svc telegramService) GetMe() (model.BotInfo, error) {
-	var botInfo model.BotInfo
-	var err error
-
-	url := viper.GetString("telegram.base_url_fmt") + viper.GetString("telegram.endpoints.get_me")
-	res, err := http.Get(fmt.Sprintf(url, svc.Token))
+	botInfo, err := svc.client.GetMe()
 	if err != nil {
 		return botInfo, fmt.Errorf("failed to get bot, %s", err.Error())
 	}
 
-	defer res.Body.Close()
+	log.Printf("[GetMe] Bot info -  ID: %d, Name: %s, Username: %s\n", botInfo.ID, botInfo.Name, botInfo.Username)
+
+	return botInfo, nil
+}
 
-	log.Printf("[GetMe] Response status: %s", res.Status)
-	if res.StatusCode >= 300 {
-		return botInfo, fmt.Errorf("bad response status when getting bot info: %s", res.Status)
+func (svc telegramService) RegisterWebhook(url string, secretToken string) error {
+	if err := svc.client.SetWebhook(model.SetWebhook{URL: url, SecretToken: secretToken}); err != nil {
+		return fmt.Errorf("failed to set webhook, %s", err.Error())
 	}
 
-	var botInfoWrapper model.BotInfoResponseWrapper
-	err = util.UnmarshalBody(res.Body, &botInfoWrapper)
-	if err != nil {
-		return botInfo, fmt.Errorf("failed to marshall getMe response to struct: %s", err.Error())
+	log.Printf("[RegisterWebhook] Registered webhook %s\n", url)
+
+	return nil
+}
+
+func (svc telegramService) DeleteWebhook() error {
+	if err := svc.client.DeleteWebhook(); err != nil {
+		return fmt.Errorf("failed to delete webhook, %s", err.Error())
 	}
 
-	log.Printf("[GetMe] /getMe response succeeded: %t.\n", botInfoWrapper.OK)
-	if botInfoWrapper.OK {
-		log.Printf("[GetMe] Bot info -  ID: %d, Name: %s, Username: %s\n", botInfoWrapper.Result.ID, botInfoWrapper.Result.Name, botInfoWrapper.Result.Username)
+	return nil
+}
+
+func (svc telegramService) GetWebhookInfo() (model.WebhookInfo, error) {
+	webhookInfo, err := svc.client.GetWebhookInfo()
+	if err != nil {
+		return webhookInfo, fmt.Errorf("failed to get webhook info, %s", err.Error())
 	}
 
-	return botInfoWrapper.Result, nil
+	return webhookInfo, nil
 }
 
-func (svc telegramService) RegisterWebhook(url string) error {
-	telegramURLFormat := viper.GetString("telegram.base_url_fmt") + viper.GetString("telegram.endpoints.set_webhook_fmt")
-	telegramURL := fmt.Sprintf(telegramURLFormat, svc.Token, url)
+func (svc telegramService) SetMyCommands(commands []model.BotCommand) error {
+	if err := svc.client.SetMyCommands(commands); err != nil {
+		return fmt.Errorf("failed to set commands, %s", err.Error())
+	}
+
+	return nil
+}
 
-	res, err := http.Post(telegramURL, "", nil)
+// GetUpdates long polls Telegram for updates with an ID of at least offset,
+// waiting up to timeout seconds for one to arrive or until ctx is done.
+func (svc telegramService) GetUpdates(ctx context.Context, offset int64, timeout int) ([]model.ReceivedMessage, error) {
+	updates, err := svc.client.GetUpdates(ctx, model.GetUpdates{Offset: offset, Timeout: timeout})
 	if err != nil {
-		return fmt.Errorf("failed to do POST request to %s: %s", telegramURL, err.Error())
+		return nil, fmt.Errorf("failed to get updates, %s", err.Error())
 	}
 
-	log.Printf("[RegisterWebhook] Response status: %s", res.Status)
-	if res.StatusCode >= 300 {
-		return fmt.Errorf("bad response status when setting webhook: %s", res.Status)
+	return updates, nil
+}
+
+// PollUpdates repeatedly calls GetUpdates and responds to each update in
+// order, advancing the offset so Telegram does not resend handled updates.
+// It returns once ctx is done, after finishing the update it's handling.
+func (svc telegramService) PollUpdates(ctx context.Context) {
+	var offset int64
+	timeout := viper.GetInt("telegram.poll_timeout_seconds")
+	if timeout <= 0 {
+		timeout = defaultPollTimeoutSeconds
 	}
 
-	defer res.Body.Close()
+	for ctx.Err() == nil {
+		updates, err := svc.GetUpdates(ctx, offset, timeout)
+		if ctx.Err() != nil {
+			break
+		}
 
-	var setWebhookResponse model.SetWebhookResponse
-	if err := util.UnmarshalBody(res.Body, &setWebhookResponse); err != nil {
-		return fmt.Errorf("failed to marshall setWebhook response to struct: %s", err.Error())
-	}
+		if err != nil {
+			log.Printf("[PollUpdates] Failed to get updates: %s", err.Error())
+
+			select {
+			case <-time.After(pollRetryDelay):
+			case <-ctx.Done():
+			}
+
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+
+			log.Printf(
+				"[PollUpdates] Got message - chat ID: %d, message ID: %d, user ID: %d, text: \"%s\"",
+				update.Message.Chat.ID,
+				update.Message.MessageID,
+				update.Message.From.ID,
+				update.Message.Text,
+			)
 
-	log.Printf("[RegisterWebhook] /setWebhook response succeeded: %t.\n", setWebhookResponse.OK)
-	if setWebhookResponse.OK {
-		log.Printf("[RegisterWebhook] Result: %t, Description: %s\n", setWebhookResponse.Result, setWebhookResponse.Description)
+			if err := svc.RespondToMessage(update); err != nil {
+				log.Printf("[PollUpdates] Error responding to message: %s", err.Error())
+			}
+
+			if ctx.Err() != nil {
+				break
+			}
+		}
 	}
 
-	return nil
+	// Telegram only forgets updates once a later poll asks past them, so
+	// confirm the handled ones or they're delivered again on the next start
+	if offset != 0 {
+		if _, err := svc.GetUpdates(context.Background(), offset, 0); err != nil {
+			log.Printf("[PollUpdates] Failed to confirm handled updates: %s", err.Error())
+		}
+	}
 }
 
 func (svc telegramService) RespondToMessage(message model.ReceivedMessage) error {
+	switch {
+	case message.CallbackQuery != nil:
+		return svc.respondToCallbackQuery(message)
+	case message.Poll != nil:
+		svc.BotService.HandlePoll(*message.Poll)
+		return nil
+	case message.PollAnswer != nil:
+		// Polls are settled on their final counts, individual votes don't
+		// matter
+		return nil
+	}
+
 	responseMessage := svc.BotService.CreateResponseMessage(message)
+	if responseMessage == nil {
+		return nil
+	}
 
 	log.Printf(
 		"[RespondToMessage] Response message - chat ID: %d, message ID: %d, user ID: %d, text: \"%s\"",
@@ -124,65 +212,62 @@ func (svc telegramService) RespondToMessage(message model.ReceivedMessage) error
 		responseMessage.Text,
 	)
 
-	var sendMessageURL string
-	var req *http.Request
-	var err error
-	if len(responseMessage.ReplyMarkup.Keyboard) > 0 || responseMessage.ParseMode != "" {
-		postBody, err := json.Marshal(responseMessage)
-		if err != nil {
-			return fmt.Errorf("failed to marshal struct %v to json: %s", responseMessage, err.Error())
-		}
-
-		messagePostURL := viper.GetString("telegram.base_url_fmt") + viper.GetString("telegram.endpoints.send_message")
-		sendMessageURL = fmt.Sprintf(messagePostURL, svc.Token)
-		req, err = http.NewRequest("POST", sendMessageURL, bytes.NewBuffer(postBody))
-		if err != nil {
-			return fmt.Errorf("failed to make POST request to %s: %s", sendMessageURL, err.Error())
-		}
+	if responseMessage.Text == "" {
+		return nil
+	}
 
-		req.Header.Set("Content-Type", "application/json")
-	} else {
-		sendMessageURL = formatSendMessageURL(svc.Token, responseMessage.ChatID, responseMessage.Text)
-		fmt.Printf("\n!!!\n%s\n!!!\n", sendMessageURL)
-		req, err = http.NewRequest("GET", sendMessageURL, nil)
-		if err != nil {
-			return fmt.Errorf("failed to make GET request to %s: %s", sendMessageURL, err.Error())
-		}
+	return svc.SendMessage(*responseMessage)
+}
 
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+func (svc telegramService) SendMessage(message model.Message) error {
+	if _, err := svc.client.SendMessage(message); err != nil {
+		return fmt.Errorf("failed to send message, %s", err.Error())
 	}
 
-	client := &http.Client{}
-	res, err := client.Do(req)
+	return nil
+}
+
+func (svc telegramService) SendPoll(poll model.SendPoll) (model.SendMessageResult, error) {
+	sent, err := svc.client.SendPoll(poll)
 	if err != nil {
-		return fmt.Errorf("failed to do request to %s: %s", sendMessageURL, err.Error())
+		return sent, fmt.Errorf("failed to send poll, %s", err.Error())
 	}
 
-	defer res.Body.Close()
+	return sent, nil
+}
 
-	log.Printf("[RespondToMessage] Response status: %s", res.Status)
+func (svc telegramService) StopPoll(stop model.StopPoll) (model.Poll, error) {
+	poll, err := svc.client.StopPoll(stop)
+	if err != nil {
+		return poll, fmt.Errorf("failed to stop poll, %s", err.Error())
+	}
 
-	var sendMessageResponse model.SendMessageResponse
-	if err := util.UnmarshalBody(res.Body, &sendMessageResponse); err != nil {
-		return fmt.Errorf("failed to marshall sendMessage response to struct: %s", err.Error())
+	return poll, nil
+}
+
+// respondToCallbackQuery edits the message whose button was pressed, then
+// answers the callback so the button stops showing as loading.
+func (svc telegramService) respondToCallbackQuery(message model.ReceivedMessage) error {
+	edit, answer := svc.BotService.CreateCallbackResponse(message)
+
+	if edit != nil {
+		log.Printf(
+			"[respondToCallbackQuery] Editing message - chat ID: %d, message ID: %d, text: \"%s\"",
+			edit.ChatID,
+			edit.MessageID,
+			edit.Text,
+		)
+
+		// Pressing the same button twice edits the message to what it already
+		// says, which Telegram reports as an error but is harmless.
+		if err := svc.client.EditMessageText(*edit); err != nil && !telegram.IsMessageNotModified(err) {
+			return fmt.Errorf("failed to edit message, %s", err.Error())
+		}
 	}
 
-	log.Printf("[RespondToMessage] /sendMessage response succeeded: %t.\n", sendMessageResponse.OK)
-	if !sendMessageResponse.OK {
-		return fmt.Errorf("failed to send message, %s", sendMessageResponse.Description)
+	if err := svc.client.AnswerCallbackQuery(*answer); err != nil {
+		return fmt.Errorf("failed to answer callback query, %s", err.Error())
 	}
 
 	return nil
 }
-
-func formatSendMessageURL(token string, chatId int64, responseText string) string {
-	sendMessageURL := viper.GetString("telegram.base_url_fmt") + viper.GetString("telegram.endpoints.send_message") + "?chat_id=%d&text=%s"
-
-	fmt.Printf("\n???\n%s\n???\n", sendMessageURL)
-	return fmt.Sprintf(
-		sendMessageURL,
-		token,
-		chatId,
-		url.QueryEscape(responseText),
-	)
-}
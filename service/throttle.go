@@ -0,0 +1,112 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// throttleSweepInterval is how often buckets that have refilled completely
+// are dropped, so the throttle doesn't keep one for every user it has seen.
+const throttleSweepInterval = 10 * time.Minute
+
+var (
+	throttledUserUpdates = NewCounter("throttled_user_updates_total")
+	throttledChatUpdates = NewCounter("throttled_chat_updates_total")
+)
+
+// keyedThrottle keeps a token bucket per key, such as a user or chat ID. A
+// nil keyedThrottle allows everything.
+type keyedThrottle struct {
+	lock      sync.Mutex
+	rate      float64
+	burst     int
+	buckets   map[int64]*tokenBucket
+	lastSweep time.Time
+}
+
+// newKeyedThrottle allows burst events per key at once, refilling at
+// perMinute events a minute. It returns nil, allowing everything, when
+// perMinute isn't positive.
+func newKeyedThrottle(perMinute float64, burst int) *keyedThrottle {
+	if perMinute <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &keyedThrottle{
+		rate:      perMinute / 60,
+		burst:     burst,
+		buckets:   make(map[int64]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token for key, or returns how long to wait for one.
+func (throttle *keyedThrottle) Allow(key int64) (bool, time.Duration) {
+	if throttle == nil {
+		return true, 0
+	}
+
+	bucket := throttle.bucket(key)
+	if bucket.Allow() {
+		return true, 0
+	}
+
+	return false, bucket.Delay()
+}
+
+func (throttle *keyedThrottle) bucket(key int64) *tokenBucket {
+	throttle.lock.Lock()
+	defer throttle.lock.Unlock()
+
+	if time.Since(throttle.lastSweep) > throttleSweepInterval {
+		for key, bucket := range throttle.buckets {
+			if bucket.Full() {
+				delete(throttle.buckets, key)
+			}
+		}
+
+		throttle.lastSweep = time.Now()
+	}
+
+	bucket, ok := throttle.buckets[key]
+	if !ok {
+		bucket = newTokenBucket(throttle.rate, throttle.burst)
+		throttle.buckets[key] = bucket
+	}
+
+	return bucket
+}
+
+// updateThrottle limits how often a single user, and a single chat, can
+// make the bot do any work.
+type updateThrottle struct {
+	users *keyedThrottle
+	chats *keyedThrottle
+}
+
+func newUpdateThrottle(userPerMinute float64, userBurst int, chatPerMinute float64, chatBurst int) updateThrottle {
+	return updateThrottle{
+		users: newKeyedThrottle(userPerMinute, userBurst),
+		chats: newKeyedThrottle(chatPerMinute, chatBurst),
+	}
+}
+
+// Allow checks the user first so a user who is over their limit doesn't use
+// up the chat's allowance. When not allowed it returns how long to wait.
+func (throttle updateThrottle) Allow(userID int64, chatID int64) (bool, time.Duration) {
+	if ok, wait := throttle.users.Allow(userID); !ok {
+		throttledUserUpdates.Inc()
+		return false, wait
+	}
+
+	if ok, wait := throttle.chats.Allow(chatID); !ok {
+		throttledChatUpdates.Inc()
+		return false, wait
+	}
+
+	return true, 0
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+const (
+	defaultUpdateQueueSize = 100
+	// minUpdatesPerWorker keeps room for a few updates behind the one a
+	// worker is handling, even when a small queue is split between many.
+	minUpdatesPerWorker = 10
+)
+
+var (
+	// ErrUpdateQueueFull is returned when the workers are too far behind to
+	// take another update. Telegram delivers it again later.
+	ErrUpdateQueueFull = errors.New("update queue is full")
+	// ErrUpdateQueueClosed is returned for updates arriving while shutting
+	// down.
+	ErrUpdateQueueClosed = errors.New("update queue is closed")
+)
+
+var (
+	queuedUpdates    = NewCounter("updates_queued_total")
+	rejectedUpdates  = NewCounter("updates_rejected_total")
+	duplicateUpdates = NewCounter("updates_duplicate_total")
+	failedUpdates    = NewCounter("updates_failed_total")
+	updateQueueDepth = NewGauge("updates_queue_length")
+)
+
+// UpdateQueue hands updates to a fixed number of workers so whoever received
+// them, such as the webhook handler, can return straight away. An update
+// that's been accepted before isn't queued again.
+//
+// Each chat's updates always go to the same worker, which handles them in
+// order. A chat sending a burst of updates only holds up its own worker and
+// the chats that share it, never every worker at once.
+type UpdateQueue struct {
+	queues  []chan model.ReceivedMessage
+	handle  func(model.ReceivedMessage) error
+	workers sync.WaitGroup
+	seen    *SeenUpdates
+
+	lock   sync.Mutex
+	closed bool
+}
+
+// NewUpdateQueue starts workers goroutines calling handle for each update,
+// holding up to size updates waiting, split evenly between the workers but
+// with room for at least minUpdatesPerWorker each.
+// Accepted update IDs are recorded in seen.
+func NewUpdateQueue(workers int, size int, seen *SeenUpdates, handle func(model.ReceivedMessage) error) *UpdateQueue {
+	if workers < 1 {
+		workers = 1
+	}
+
+	if size < 1 {
+		size = defaultUpdateQueueSize
+	}
+
+	perWorker := (size + workers - 1) / workers
+	if perWorker < minUpdatesPerWorker {
+		perWorker = minUpdatesPerWorker
+	}
+
+	queue := &UpdateQueue{
+		queues: make([]chan model.ReceivedMessage, workers),
+		handle: handle,
+		seen:   seen,
+	}
+
+	queue.workers.Add(workers)
+	for i := range queue.queues {
+		queue.queues[i] = make(chan model.ReceivedMessage, perWorker)
+		go queue.work(queue.queues[i])
+	}
+
+	return queue
+}
+
+// Enqueue adds update to its chat's worker's queue without waiting, returning
+// ErrUpdateQueueFull when there's no room for it. Updates that were seen
+// before are dropped without an error, so they're acknowledged.
+func (queue *UpdateQueue) Enqueue(update model.ReceivedMessage) error {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	if queue.closed {
+		rejectedUpdates.Inc()
+		return ErrUpdateQueueClosed
+	}
+
+	if queue.seen.Contains(update.UpdateID) {
+		log.Printf("[UpdateQueue] Ignoring duplicate update %d", update.UpdateID)
+		duplicateUpdates.Inc()
+		return nil
+	}
+
+	select {
+	case queue.queueFor(update) <- update:
+		queue.seen.Add(update.UpdateID)
+		queuedUpdates.Inc()
+		updateQueueDepth.Set(queue.depth())
+		return nil
+	default:
+		rejectedUpdates.Inc()
+		return ErrUpdateQueueFull
+	}
+}
+
+// Close stops accepting updates and waits until the ones already queued have
+// been handled, or ctx is done.
+func (queue *UpdateQueue) Close(ctx context.Context) error {
+	queue.lock.Lock()
+	if !queue.closed {
+		queue.closed = true
+		for _, updates := range queue.queues {
+			close(updates)
+		}
+	}
+	queue.lock.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		queue.workers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (queue *UpdateQueue) work(updates chan model.ReceivedMessage) {
+	defer queue.workers.Done()
+
+	for update := range updates {
+		updateQueueDepth.Set(queue.depth())
+
+		if err := queue.handle(update); err != nil {
+			log.Printf("[UpdateQueue] Error handling update %d: %s", update.UpdateID, err.Error())
+			failedUpdates.Inc()
+		}
+	}
+}
+
+// queueFor picks the queue of the worker that handles update's chat.
+func (queue *UpdateQueue) queueFor(update model.ReceivedMessage) chan model.ReceivedMessage {
+	chatID := updateChatID(update)
+
+	return queue.queues[uint64(chatID)%uint64(len(queue.queues))]
+}
+
+// depth is how many updates are waiting for any worker.
+func (queue *UpdateQueue) depth() int64 {
+	var depth int
+	for _, updates := range queue.queues {
+		depth += len(updates)
+	}
+
+	return int64(depth)
+}
+
+// updateChatID returns the chat an update belongs to. Poll updates aren't
+// from a chat and all share chat 0, poll answers use the voter's ID.
+func updateChatID(update model.ReceivedMessage) int64 {
+	switch {
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.Message.Chat.ID
+	case update.Poll != nil:
+		return 0
+	case update.PollAnswer != nil:
+		return update.PollAnswer.User.ID
+	default:
+		return update.Message.Chat.ID
+	}
+}
@@ -1,10 +1,14 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/zachvanuum/FoodHelperBot/model"
@@ -12,73 +16,216 @@ import (
 )
 
 type YelpService interface {
-	SearchByLocation(term string, location string) (model.SearchResponse, error)
-	SearchByCoordinates(term string, latitude float64, longitude float64) (model.SearchResponse, error)
+	SearchByLocation(term string, location string, options model.SearchOptions) (model.SearchResponse, error)
+	SearchByCoordinates(term string, latitude float64, longitude float64, options model.SearchOptions) (model.SearchResponse, error)
+	GetBusiness(id string) (model.BusinessDetails, error)
+	GetReviews(businessID string) (model.ReviewsResponse, error)
 }
 
+const (
+	// Yelp refuses searches where offset + limit goes past this many results.
+	YelpMaxResults = 1000
+	// The largest search radius Yelp accepts, in meters.
+	YelpMaxRadius = 40000
+
+	// Used when "yelp.rate_limit" isn't set, as in configs from before it
+	// existed
+	defaultYelpRatePerSecond = 5
+	defaultYelpRateBurst     = 10
+)
+
 type yelpService struct {
 	APIKey  string
 	BaseURL string
+
+	// Shared by every request so connections are reused, and so the limiter
+	// and quota cover all of the bot's Yelp traffic.
+	client  *outboundClient
+	limiter *tokenBucket
+	quota   *yelpQuota
 }
 
 func NewYelpService(apiKey string) YelpService {
+	client := newOutboundClient("yelp", "yelp")
+	client.permanent = isYelpQuotaResponse
+
+	ratePerSecond := viper.GetFloat64("yelp.rate_limit.per_second")
+	if ratePerSecond <= 0 {
+		ratePerSecond = defaultYelpRatePerSecond
+	}
+
+	burst := viper.GetInt("yelp.rate_limit.burst")
+	if burst < 1 {
+		burst = defaultYelpRateBurst
+	}
+
 	return yelpService{
 		APIKey:  apiKey,
 		BaseURL: viper.GetString("yelp.base_url"),
+		client:  client,
+		limiter: newTokenBucket(ratePerSecond, burst),
+		quota:   newYelpQuota(),
+	}
+}
+
+func (svc yelpService) SearchByLocation(term string, location string, options model.SearchOptions) (model.SearchResponse, error) {
+	query := searchQuery(term, options)
+	query.Set("location", location)
+
+	return svc.search(svc.BaseURL + viper.GetString("yelp.endpoints.business_search") + "?" + query.Encode())
+}
+
+func (svc yelpService) SearchByCoordinates(term string, latitude float64, longitude float64, options model.SearchOptions) (model.SearchResponse, error) {
+	query := searchQuery(term, options)
+	query.Set("latitude", strconv.FormatFloat(latitude, 'f', -1, 64))
+	query.Set("longitude", strconv.FormatFloat(longitude, 'f', -1, 64))
+
+	return svc.search(svc.BaseURL + viper.GetString("yelp.endpoints.business_search") + "?" + query.Encode())
+}
+
+func searchQuery(term string, options model.SearchOptions) url.Values {
+	query := url.Values{}
+	query.Set("term", term)
+
+	if options.Offset > 0 {
+		query.Set("offset", strconv.Itoa(options.Offset))
+	}
+
+	if options.Limit > 0 {
+		query.Set("limit", strconv.Itoa(options.Limit))
+	}
+
+	if len(options.Price) > 0 {
+		prices := []string{}
+		for _, price := range options.Price {
+			prices = append(prices, strconv.Itoa(price))
+		}
+
+		query.Set("price", strings.Join(prices, ","))
+	}
+
+	if options.OpenNow {
+		query.Set("open_now", "true")
+	}
+
+	if options.Radius > 0 {
+		radius := options.Radius
+		if radius > YelpMaxRadius {
+			radius = YelpMaxRadius
+		}
+
+		query.Set("radius", strconv.Itoa(radius))
+	}
+
+	if options.SortBy != "" {
+		query.Set("sort_by", options.SortBy)
+	}
+
+	if len(options.Categories) > 0 {
+		query.Set("categories", strings.Join(options.Categories, ","))
 	}
+
+	if len(options.Attributes) > 0 {
+		query.Set("attributes", strings.Join(options.Attributes, ","))
+	}
+
+	return query
 }
 
-func (svc yelpService) SearchByLocation(term string, location string) (model.SearchResponse, error) {
-	yelpURL := svc.BaseURL + viper.GetString("yelp.endpoints.business_search") + "?term=%s&location=%s"
-	searchURL := fmt.Sprintf(
-		yelpURL,
-		url.QueryEscape(term),
-		url.QueryEscape(location),
-	)
+func (svc yelpService) GetBusiness(id string) (model.BusinessDetails, error) {
+	var details model.BusinessDetails
+
+	businessURL := svc.BaseURL + fmt.Sprintf(viper.GetString("yelp.endpoints.business_details_fmt"), url.PathEscape(id))
+	if err := svc.get(businessURL, &details); err != nil {
+		return details, fmt.Errorf("failed to get business %s: %w", id, err)
+	}
+
+	return details, nil
+}
+
+func (svc yelpService) GetReviews(businessID string) (model.ReviewsResponse, error) {
+	var reviews model.ReviewsResponse
+
+	reviewsURL := svc.BaseURL + fmt.Sprintf(viper.GetString("yelp.endpoints.business_reviews_fmt"), url.PathEscape(businessID))
+	if err := svc.get(reviewsURL, &reviews); err != nil {
+		return reviews, fmt.Errorf("failed to get reviews for business %s: %w", businessID, err)
+	}
 
-	return svc.search(searchURL)
+	return reviews, nil
 }
 
-func (svc yelpService) SearchByCoordinates(term string, latitude float64, longitude float64) (model.SearchResponse, error) {
-	yelpURL := svc.BaseURL + viper.GetString("yelp.endpoints.business_search") + "?term=%s&latitude=%f&longitude=%f"
-	searchURL := fmt.Sprintf(
-		yelpURL,
-		url.QueryEscape(term),
-		latitude,
-		longitude,
-	)
+// get requests a single Yelp resource and unmarshals it into target.
+func (svc yelpService) get(url string, target interface{}) error {
+	res, err := svc.doRequest(url)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
 
-	return svc.search(searchURL)
+	if err := util.UnmarshalBody(res.Body, target); err != nil {
+		return fmt.Errorf("failed to marshall response to struct: %s", err.Error())
+	}
+
+	return nil
 }
 
 func (svc yelpService) search(url string) (model.SearchResponse, error) {
-	res, err := doSearchRequest(url, svc.APIKey)
+	res, err := svc.doRequest(url)
 	if err != nil {
 		return model.SearchResponse{}, err
 	}
 
 	defer res.Body.Close()
 
-	log.Printf("[SearchByCoordinates] Response status: %s", res.Status)
-
 	searchResponse, err := handleSearchResponse(res)
+	if err != nil {
+		return model.SearchResponse{}, err
+	}
 
 	return filterClosedResults(searchResponse), nil
 }
 
-func doSearchRequest(url string, key string) (*http.Response, error) {
-	log.Printf("[doSearchRequest] Search request to Yelp: %s", url)
+// doRequest makes a GET request to Yelp once the rate limiter allows it,
+// refusing straight away while the daily quota is used up. Responses that
+// aren't successful are closed and returned as a *YelpError.
+func (svc yelpService) doRequest(url string) (*http.Response, error) {
+	if svc.quota.Exhausted() {
+		yelpQuotaRejections.Inc()
+		return nil, ErrYelpQuotaExhausted
+	}
+
+	if wait := svc.limiter.Reserve(); wait > 0 {
+		yelpRateLimitWaiting.Inc()
+		time.Sleep(wait)
+	}
+
+	log.Printf("[doRequest] Request to Yelp: %s", url)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("[doSearchRequest] failed to create GET request for /businesses/search bot, %s", err.Error())
+		return nil, fmt.Errorf("[doRequest] failed to create GET request, %s", err.Error())
 	}
-	addBearerToken(req, key)
+	addBearerToken(req, svc.APIKey)
 
-	client := &http.Client{}
-	res, err := client.Do(req)
+	yelpRequests.Inc()
+	res, err := svc.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to do GET request to %s: %s", req.URL.RawPath, err.Error())
+		return nil, fmt.Errorf("%w, GET request to %s failed: %s", ErrYelpUnavailable, req.URL.Path, err.Error())
+	}
+
+	log.Printf("[doRequest] Response status: %s", res.Status)
+	svc.quota.Update(res.Header)
+
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+
+		yelpErr := yelpErrorFromResponse(res)
+		if errors.Is(yelpErr, ErrYelpQuotaExhausted) {
+			svc.quota.MarkExhausted(res.Header)
+		}
+
+		return nil, yelpErr
 	}
 
 	return res, nil
@@ -95,18 +242,7 @@ func handleSearchResponse(res *http.Response) (model.SearchResponse, error) {
 		return searchResponse, fmt.Errorf("failed to marshall search response to struct: %s", err.Error())
 	}
 
-	log.Printf("[Search] Search got %d results", searchResponse.Total)
-
-	if searchResponse.Total == 0 {
-		log.Printf("[Search] Checking for error response")
-
-		var errorResponse model.ErrorResponseWrapper
-		if err := util.UnmarshalBody(res.Body, &errorResponse); err != nil {
-			return searchResponse, fmt.Errorf("failed to marshall error response to struct: %s", err.Error())
-		}
-
-		log.Printf("[Search] Error response - Code: %s, Description: %s", errorResponse.Error.Code, errorResponse.Error.Description)
-	}
+	log.Printf("[handleSearchResponse] Search got %d results", searchResponse.Total)
 
 	return searchResponse, nil
 }
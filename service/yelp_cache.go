@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+)
+
+// Searches around coordinates in the same geohash cell of this precision,
+// about 150m across, share a cache entry.
+const cacheGeohashPrecision = 7
+
+var (
+	yelpCacheHits      = NewCounter("yelp_cache_hits_total")
+	yelpCacheMisses    = NewCounter("yelp_cache_misses_total")
+	yelpCacheEvictions = NewCounter("yelp_cache_evictions_total")
+)
+
+// How long business details are cached when "yelp.cache.business_ttl" isn't
+// set. They say whether a business is open now, so they go stale quickly.
+const defaultBusinessCacheTTL = time.Minute
+
+// cachingYelpService remembers Yelp responses so a group asking for the same
+// thing again doesn't spend more of the daily quota. Failed requests aren't
+// cached.
+type cachingYelpService struct {
+	yelp        YelpService
+	cache       *lruCache
+	businessTTL time.Duration
+}
+
+// NewCachingYelpService wraps yelp with a cache of at most size responses,
+// each kept for up to ttl, or businessTTL for business details.
+func NewCachingYelpService(yelp YelpService, size int, ttl time.Duration, businessTTL time.Duration) YelpService {
+	cache := newLRUCache(size, ttl)
+	cache.onEvict = yelpCacheEvictions.Inc
+
+	if businessTTL <= 0 {
+		businessTTL = defaultBusinessCacheTTL
+	}
+
+	return cachingYelpService{
+		yelp:        yelp,
+		cache:       cache,
+		businessTTL: businessTTL,
+	}
+}
+
+func (svc cachingYelpService) SearchByLocation(term string, location string, options model.SearchOptions) (model.SearchResponse, error) {
+	key := fmt.Sprintf("search|%s|%s|%s", normalizeCacheText(term), normalizeCacheText(location), searchQuery("", options).Encode())
+
+	response, err := svc.cached(key, svc.cache.ttl, func() (interface{}, error) {
+		return svc.yelp.SearchByLocation(term, location, options)
+	})
+	if err != nil {
+		return model.SearchResponse{}, err
+	}
+
+	return response.(model.SearchResponse), nil
+}
+
+// SearchByCoordinates shares results between coordinates in the same geohash
+// cell, so distances in a cached response are from whoever searched first.
+func (svc cachingYelpService) SearchByCoordinates(term string, latitude float64, longitude float64, options model.SearchOptions) (model.SearchResponse, error) {
+	key := fmt.Sprintf("search|%s|@%s|%s", normalizeCacheText(term), geohash(latitude, longitude, cacheGeohashPrecision), searchQuery("", options).Encode())
+
+	response, err := svc.cached(key, svc.cache.ttl, func() (interface{}, error) {
+		return svc.yelp.SearchByCoordinates(term, latitude, longitude, options)
+	})
+	if err != nil {
+		return model.SearchResponse{}, err
+	}
+
+	return response.(model.SearchResponse), nil
+}
+
+func (svc cachingYelpService) GetBusiness(id string) (model.BusinessDetails, error) {
+	details, err := svc.cached("business|"+id, svc.businessTTL, func() (interface{}, error) {
+		return svc.yelp.GetBusiness(id)
+	})
+	if err != nil {
+		return model.BusinessDetails{}, err
+	}
+
+	return details.(model.BusinessDetails), nil
+}
+
+func (svc cachingYelpService) GetReviews(businessID string) (model.ReviewsResponse, error) {
+	reviews, err := svc.cached("reviews|"+businessID, svc.cache.ttl, func() (interface{}, error) {
+		return svc.yelp.GetReviews(businessID)
+	})
+	if err != nil {
+		return model.ReviewsResponse{}, err
+	}
+
+	return reviews.(model.ReviewsResponse), nil
+}
+
+func (svc cachingYelpService) cached(key string, ttl time.Duration, fetch func() (interface{}, error)) (interface{}, error) {
+	if value, ok := svc.cache.Get(key); ok {
+		yelpCacheHits.Inc()
+		return value, nil
+	}
+
+	yelpCacheMisses.Inc()
+
+	value, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	svc.cache.AddWithTTL(key, value, ttl)
+
+	return value, nil
+}
+
+// normalizeCacheText lower cases text and collapses its spacing so "Tacos "
+// and "tacos" share a cache entry.
+func normalizeCacheText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+	"github.com/zachvanuum/FoodHelperBot/util"
+)
+
+// Kinds of Yelp failure the bot tells users about. Errors from the Yelp client
+// wrap one of these, check for them with errors.Is.
+var (
+	ErrYelpInvalidLocation = errors.New("yelp could not find the location")
+	ErrYelpUnauthorized    = errors.New("yelp rejected the api key")
+	ErrYelpRateLimited     = errors.New("yelp rate limited the request")
+	ErrYelpUnavailable     = errors.New("yelp is unavailable")
+)
+
+// YelpError is a failed response from Yelp, with the error Yelp sent back.
+type YelpError struct {
+	StatusCode  int
+	Code        string
+	Description string
+
+	// One of the ErrYelp errors, or nil when the failure isn't one the bot
+	// treats specially
+	Kind error
+}
+
+func (err *YelpError) Error() string {
+	return fmt.Sprintf("yelp responded %d %s: %s", err.StatusCode, err.Code, err.Description)
+}
+
+func (err *YelpError) Unwrap() error {
+	return err.Kind
+}
+
+// yelpErrorFromResponse reads the error Yelp sent with a failed response.
+func yelpErrorFromResponse(res *http.Response) *YelpError {
+	var errorResponse model.ErrorResponseWrapper
+	if err := util.UnmarshalBody(res.Body, &errorResponse); err != nil {
+		errorResponse.Error.Description = res.Status
+	}
+
+	return &YelpError{
+		StatusCode:  res.StatusCode,
+		Code:        errorResponse.Error.Code,
+		Description: errorResponse.Error.Description,
+		Kind:        yelpErrorKind(res.StatusCode, errorResponse.Error.Code),
+	}
+}
+
+// isYelpQuotaResponse reports whether a response body is Yelp saying the daily
+// quota has run out, which retrying won't fix.
+func isYelpQuotaResponse(body []byte) bool {
+	var errorResponse model.ErrorResponseWrapper
+	if err := json.Unmarshal(body, &errorResponse); err != nil {
+		return false
+	}
+
+	return errorResponse.Error.Code == yelpAccessLimitReachedCode
+}
+
+func yelpErrorKind(statusCode int, code string) error {
+	switch {
+	case code == "LOCATION_NOT_FOUND" || code == "LOCATION_MISSING":
+		return ErrYelpInvalidLocation
+	case code == yelpAccessLimitReachedCode:
+		return ErrYelpQuotaExhausted
+	case statusCode == http.StatusTooManyRequests:
+		return ErrYelpRateLimited
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrYelpUnauthorized
+	case statusCode >= 500:
+		return ErrYelpUnavailable
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	yelpDailyLimitHeader = "RateLimit-DailyLimit"
+	yelpRemainingHeader  = "RateLimit-Remaining"
+	yelpResetTimeHeader  = "RateLimit-ResetTime"
+
+	// Yelp's error code when the daily quota is used up
+	yelpAccessLimitReachedCode = "ACCESS_LIMIT_REACHED"
+)
+
+// ErrYelpQuotaExhausted is returned instead of calling Yelp once the daily
+// quota has run out, until Yelp says it resets.
+var ErrYelpQuotaExhausted = errors.New("yelp daily quota exhausted")
+
+var (
+	yelpRequests         = NewCounter("yelp_requests_total")
+	yelpQuotaRejections  = NewCounter("yelp_quota_rejections_total")
+	yelpQuotaRemaining   = NewGauge("yelp_quota_remaining")
+	yelpQuotaDailyLimit  = NewGauge("yelp_quota_daily_limit")
+	yelpRateLimitWaiting = NewCounter("yelp_rate_limit_waits_total")
+)
+
+// yelpQuota tracks how much of the daily quota is left from the headers Yelp
+// sends with every response.
+type yelpQuota struct {
+	lock       sync.Mutex
+	known      bool
+	dailyLimit int
+	remaining  int
+	resetTime  time.Time
+	now        func() time.Time
+}
+
+func newYelpQuota() *yelpQuota {
+	return &yelpQuota{now: time.Now}
+}
+
+func (quota *yelpQuota) Update(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get(yelpRemainingHeader))
+	if err != nil {
+		return
+	}
+
+	quota.lock.Lock()
+	defer quota.lock.Unlock()
+
+	quota.known = true
+	quota.remaining = remaining
+	yelpQuotaRemaining.Set(int64(remaining))
+
+	if dailyLimit, err := strconv.Atoi(header.Get(yelpDailyLimitHeader)); err == nil {
+		quota.dailyLimit = dailyLimit
+		yelpQuotaDailyLimit.Set(int64(dailyLimit))
+	}
+
+	if resetTime, err := time.Parse(time.RFC3339, header.Get(yelpResetTimeHeader)); err == nil {
+		quota.resetTime = resetTime
+	} else {
+		quota.resetTime = nextUTCMidnight(quota.now())
+	}
+}
+
+// MarkExhausted records that Yelp refused a request for being over quota.
+func (quota *yelpQuota) MarkExhausted(header http.Header) {
+	quota.lock.Lock()
+	defer quota.lock.Unlock()
+
+	quota.known = true
+	quota.remaining = 0
+	yelpQuotaRemaining.Set(0)
+
+	if resetTime, err := time.Parse(time.RFC3339, header.Get(yelpResetTimeHeader)); err == nil {
+		quota.resetTime = resetTime
+	} else {
+		quota.resetTime = nextUTCMidnight(quota.now())
+	}
+
+	log.Printf("[yelpQuota] Daily quota of %d exhausted until %s", quota.dailyLimit, quota.resetTime)
+}
+
+func (quota *yelpQuota) Exhausted() bool {
+	quota.lock.Lock()
+	defer quota.lock.Unlock()
+
+	return quota.known && quota.remaining <= 0 && quota.now().Before(quota.resetTime)
+}
+
+func nextUTCMidnight(now time.Time) time.Time {
+	year, month, day := now.UTC().Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,203 @@
+// Package telegram is a client for the parts of the Telegram Bot API the bot
+// uses. Every method is a JSON POST, and failures Telegram reports are
+// returned as *Error.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/zachvanuum/FoodHelperBot/model"
+	"github.com/zachvanuum/FoodHelperBot/util"
+)
+
+const (
+	DefaultBaseURL = "https://api.telegram.org"
+
+	// How much longer than the long poll timeout to wait for getUpdates to
+	// answer before giving up
+	pollTimeoutMargin = 10 * time.Second
+)
+
+// Doer sends HTTP requests, *http.Client satisfies it.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type Client struct {
+	baseURL string
+	token   string
+	http    Doer
+}
+
+// NewClient makes a client for the bot with token, sending requests to
+// baseURL, or DefaultBaseURL when it's empty, with http.
+func NewClient(baseURL string, token string, http Doer) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    http,
+	}
+}
+
+// resendableMethods can be sent again when it isn't known whether Telegram got
+// them, since doing them twice is the same as doing them once.
+var resendableMethods = map[string]bool{
+	"getMe":          true,
+	"setWebhook":     true,
+	"deleteWebhook":  true,
+	"getWebhookInfo": true,
+	"getUpdates":     true,
+	"setMyCommands":  true,
+}
+
+// IsResendable reports whether a request made by a Client can be sent again
+// after a network error or timeout. Methods that send or change messages
+// can't, Telegram may already have done them and the chat would see them twice.
+func IsResendable(req *http.Request) bool {
+	return resendableMethods[path.Base(req.URL.Path)]
+}
+
+// response is the envelope Telegram wraps every result in.
+type response struct {
+	OK          bool                      `json:"ok"`
+	Result      json.RawMessage           `json:"result"`
+	ErrorCode   int                       `json:"error_code"`
+	Description string                    `json:"description"`
+	Parameters  *model.ResponseParameters `json:"parameters"`
+}
+
+func (client *Client) GetMe() (model.BotInfo, error) {
+	var botInfo model.BotInfo
+	err := client.call(context.Background(), "getMe", struct{}{}, &botInfo)
+
+	return botInfo, err
+}
+
+func (client *Client) SetWebhook(webhook model.SetWebhook) error {
+	return client.call(context.Background(), "setWebhook", webhook, nil)
+}
+
+func (client *Client) DeleteWebhook() error {
+	return client.call(context.Background(), "deleteWebhook", struct{}{}, nil)
+}
+
+func (client *Client) GetWebhookInfo() (model.WebhookInfo, error) {
+	var webhookInfo model.WebhookInfo
+	err := client.call(context.Background(), "getWebhookInfo", struct{}{}, &webhookInfo)
+
+	return webhookInfo, err
+}
+
+// GetUpdates waits for the long poll to finish, however long the client
+// otherwise waits for a response, unless ctx is done first.
+func (client *Client) GetUpdates(ctx context.Context, getUpdates model.GetUpdates) ([]model.ReceivedMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(getUpdates.Timeout)*time.Second+pollTimeoutMargin)
+	defer cancel()
+
+	var updates []model.ReceivedMessage
+	err := client.call(ctx, "getUpdates", getUpdates, &updates)
+
+	return updates, err
+}
+
+func (client *Client) SendMessage(message model.Message) (model.SendMessageResult, error) {
+	var sent model.SendMessageResult
+	err := client.call(context.Background(), "sendMessage", message, &sent)
+
+	return sent, err
+}
+
+func (client *Client) EditMessageText(edit model.EditMessageText) error {
+	return client.call(context.Background(), "editMessageText", edit, nil)
+}
+
+func (client *Client) AnswerCallbackQuery(answer model.AnswerCallbackQuery) error {
+	return client.call(context.Background(), "answerCallbackQuery", answer, nil)
+}
+
+func (client *Client) SendLocation(location model.SendLocation) (model.SendMessageResult, error) {
+	var sent model.SendMessageResult
+	err := client.call(context.Background(), "sendLocation", location, &sent)
+
+	return sent, err
+}
+
+func (client *Client) SendPhoto(photo model.SendPhoto) (model.SendMessageResult, error) {
+	var sent model.SendMessageResult
+	err := client.call(context.Background(), "sendPhoto", photo, &sent)
+
+	return sent, err
+}
+
+func (client *Client) SendPoll(poll model.SendPoll) (model.SendMessageResult, error) {
+	var sent model.SendMessageResult
+	err := client.call(context.Background(), "sendPoll", poll, &sent)
+
+	return sent, err
+}
+
+func (client *Client) StopPoll(stop model.StopPoll) (model.Poll, error) {
+	var poll model.Poll
+	err := client.call(context.Background(), "stopPoll", stop, &poll)
+
+	return poll, err
+}
+
+func (client *Client) SetMyCommands(commands []model.BotCommand) error {
+	return client.call(context.Background(), "setMyCommands", model.SetMyCommands{Commands: commands}, nil)
+}
+
+// call POSTs params as JSON to method and unmarshals the result into result,
+// unless it's nil.
+func (client *Client) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request to json: %s", method, err.Error())
+	}
+
+	req, err := http.NewRequest("POST", client.baseURL+"/bot"+client.token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to make %s request: %s", method, err.Error())
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.http.Do(req)
+	if err != nil {
+		// The URL has the token in it, so don't repeat the error's URL
+		return fmt.Errorf("failed to do %s request: %s", method, unwrapURLError(err).Error())
+	}
+
+	defer res.Body.Close()
+
+	var envelope response
+	if err := util.UnmarshalBody(res.Body, &envelope); err != nil {
+		return fmt.Errorf("failed to unmarshal %s response (%s): %s", method, res.Status, err.Error())
+	}
+
+	if !envelope.OK {
+		return newError(method, envelope)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(envelope.Result, result); err != nil {
+		return fmt.Errorf("failed to unmarshal %s result: %s", method, err.Error())
+	}
+
+	return nil
+}
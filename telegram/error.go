@@ -0,0 +1,58 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const messageNotModifiedDescription = "message is not modified"
+
+// Error is a request Telegram answered with "ok": false.
+type Error struct {
+	Method      string
+	Code        int
+	Description string
+
+	// Set when Telegram says to wait before trying again
+	RetryAfter time.Duration
+	// Set when the group was upgraded to a supergroup with this ID
+	MigrateToChatID int64
+}
+
+func newError(method string, envelope response) *Error {
+	err := &Error{
+		Method:      method,
+		Code:        envelope.ErrorCode,
+		Description: envelope.Description,
+	}
+
+	if envelope.Parameters != nil {
+		err.RetryAfter = time.Duration(envelope.Parameters.RetryAfter) * time.Second
+		err.MigrateToChatID = envelope.Parameters.MigrateToChatID
+	}
+
+	return err
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("telegram %s failed with %d: %s", err.Method, err.Code, err.Description)
+}
+
+// IsMessageNotModified reports whether err is Telegram refusing to edit a
+// message to the text it already has, which callers can usually ignore.
+func IsMessageNotModified(err error) bool {
+	var telegramErr *Error
+	return errors.As(err, &telegramErr) && strings.Contains(telegramErr.Description, messageNotModifiedDescription)
+}
+
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+
+	return err
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 )
 
 func UnmarshalBody(body io.ReadCloser, target interface{}) error {
@@ -21,3 +23,30 @@ func UnmarshalBody(body io.ReadCloser, target interface{}) error {
 
 	return fmt.Errorf("failed to read body: %s", err)
 }
+
+// WriteFileAtomic writes to a temporary file and renames it over path so a
+// crash mid-write never leaves a truncated file behind.
+func WriteFileAtomic(path string, contents []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %s", err.Error())
+	}
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %s", tmp.Name(), err.Error())
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to close %s: %s", tmp.Name(), err.Error())
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to replace %s: %s", path, err.Error())
+	}
+
+	return nil
+}